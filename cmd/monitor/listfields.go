@@ -0,0 +1,49 @@
+package main
+
+import (
+	"diploma/internal/events"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+//go:generate sh -c "go run . list-fields -markdown > ../../docs/fields.md"
+
+// runListFields prints the fields rules can use, per event type.
+func runListFields(args []string) {
+	fs := flag.NewFlagSet("list-fields", flag.ExitOnError)
+	markdown := fs.Bool("markdown", false, "print the reference in docs/fields.md format")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monitor list-fields [-markdown] [event_type...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *markdown {
+		fmt.Print(events.FieldsMarkdown())
+		return
+	}
+
+	types := fs.Args()
+	if len(types) == 0 {
+		types = events.EventTypeNames()
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, t := range types {
+		fields, ok := events.Fields(t)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Невідомий тип події %q\n", t)
+			os.Exit(2)
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s:\n", t)
+		for _, f := range fields {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", f.Name, f.Type, f.Description)
+		}
+	}
+	w.Flush()
+}
@@ -2,46 +2,429 @@ package main
 
 import (
 	"diploma/internal/analyzer"
+	"diploma/internal/capture"
 	"diploma/internal/config"
+	"diploma/internal/events"
+	"diploma/internal/exeinfo"
+	"diploma/internal/ioc"
 	"diploma/internal/loader"
+	"diploma/internal/metrics"
 	"diploma/internal/poller"
+	"diploma/internal/response"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"slices"
+	"sync/atomic"
 	"syscall"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		case "test-rules":
+			runTestRules(os.Args[2:])
+			return
+		case "list-fields":
+			runListFields(os.Args[2:])
+			return
+		}
+	}
+
+	configPath := flag.String("config", "configs/monitor.yaml", "path to monitor config")
+	flag.Parse()
+
 	log.Println("Запуск Security Monitor...")
 
-	loaded, cleanup, err := loader.Setup()
+	cfg, err := config.Load(*configPath)
 	if err != nil {
-		log.Fatalf("Помилка завантаження: %v", err)
+		log.Fatalf("Критична помилка: не вдалося завантажити конфігурацію з %s: %v", *configPath, err)
 	}
-	defer cleanup()
 
-	rulesPath := "configs/security_rules.yaml"
-	rulesCfg, err := config.LoadRules(rulesPath)
+	rulesCfg, err := config.LoadRules(cfg.RulesPath)
 	if err != nil {
-		log.Fatalf("Критична помилка: не вдалося завантажити правила з %s: %v", rulesPath, err)
+		log.Fatalf("Критична помилка: не вдалося завантажити правила з %s: %v", cfg.RulesPath, err)
 	}
 
 	log.Printf("Завантажено %d правил безпеки", len(rulesCfg.Rules))
+	if err := checkRules(rulesCfg.Rules); err != nil {
+		log.Fatalf("Критична помилка: %v", err)
+	}
+
+	if err := loadFeeds(cfg); err != nil {
+		log.Fatalf("Помилка завантаження IOC-фідів: %v", err)
+	}
+	if err := checkFeeds(rulesCfg.Rules); err != nil {
+		log.Fatalf("Критична помилка: %v", err)
+	}
+	if cfg.IOC.ReloadInterval > 0 && len(cfg.IOC.Feeds) > 0 {
+		stopFeeds := ioc.Default.Watch(cfg.IOC.ReloadInterval)
+		defer stopFeeds()
+	}
+
+	var baseline *analyzer.Baseline
+	if cfg.Baseline.Mode != analyzer.BaselineOff {
+		baseline, err = analyzer.NewBaseline(cfg.Baseline)
+		if err != nil {
+			log.Fatalf("Помилка базового профілю: %v", err)
+		}
+		defer func() {
+			if err := baseline.Close(); err != nil {
+				log.Printf("Помилка збереження базового профілю: %v", err)
+			}
+		}()
+		if cfg.OpenatPrefilter {
+			log.Println("Фільтр openat у ядрі вимкнено: потрібен для базового профілю")
+			cfg.OpenatPrefilter = false
+		}
+		if baseline.Learning() {
+			log.Printf("Базовий профіль: навчання протягом %s, збереження у %s", cfg.Baseline.LearnPeriod, cfg.Baseline.Path)
+		} else {
+			log.Printf("Базовий профіль: виявлення відхилень від %s", cfg.Baseline.Path)
+		}
+	}
+
+	enforceMode, err := loader.ParseEnforceMode(cfg.Enforcement)
+	if err != nil {
+		log.Fatalf("Критична помилка: %v", err)
+	}
+
+	kernel, err := buildKernelRules(cfg, enforceMode, rulesCfg.Rules)
+	if err != nil {
+		log.Fatalf("Критична помилка: %v", err)
+	}
+
+	loaded, cleanup, err := loader.Setup(loader.Options{
+		RingBufferSize: cfg.RingBufferSize,
+		Backend:        loader.Backend(cfg.Backend),
+		Events:         kernel.events,
+		Enforce:        enforceMode != loader.EnforceOff,
+	})
+	if err != nil {
+		log.Fatalf("Помилка завантаження: %v", err)
+	}
+	defer cleanup()
+
+	if err := loaded.ApplyIgnoreList(cfg.Ignore); err != nil {
+		log.Fatalf("Помилка списку ігнорування: %v", err)
+	}
+
+	if err := loaded.ApplyExecveLimits(cfg.Execve); err != nil {
+		log.Fatalf("Помилка налаштувань execve: %v", err)
+	}
+
+	var denyRules denyRuleNames
+	if err := kernel.applyMaps(loaded, enforceMode, &denyRules); err != nil {
+		log.Fatalf("Помилка фільтрів у ядрі: %v", err)
+	}
+
+	reportCapabilities(loaded)
+	rulesCfg.Rules = usableRules(rulesCfg.Rules, loaded)
 
 	engine := analyzer.New(*rulesCfg)
+	engine.Baseline = baseline
+
+	responder, err := response.New(cfg.Response, loaded)
+	if err != nil {
+		log.Fatalf("Помилка модуля реагування: %v", err)
+	}
+	defer responder.Close()
+	engine.Responders = append(engine.Responders, responder)
+
+	// Closed before the responder so that the queued execve events still
+	// alert. The ring buffers are read until cleanup runs after it, and their
+	// execve events are then checked without executable metadata.
+	if cfg.ExeInfo.Enabled {
+		engine.ExeInfo = exeinfo.New(cfg.ExeInfo)
+		defer engine.ExeInfo.Close()
+	}
+
+	dispatcher := poller.NewDispatcher()
+	for t, decode := range loader.Decoders() {
+		dispatcher.Register(t, decode, engine.Handle)
+	}
+	if enforceMode != loader.EnforceOff {
+		// Named on decoding, before the recorder sees the event: rule ids
+		// only mean something with the policy loaded at the time.
+		decodeDeny := events.Decoder[events.DenyEvent]()
+		dispatcher.Register(events.EventDeny, func(data []byte) (events.EventGetter, error) {
+			evt, err := decodeDeny(data)
+			if err != nil {
+				return nil, err
+			}
+			deny := evt.(*events.DenyEvent)
+			deny.RuleName = denyRules.name(deny.RuleId)
+			return deny, nil
+		}, func(evt events.EventGetter) {
+			engine.HandleDeny(evt.(*events.DenyEvent))
+		})
+	}
+	if cfg.Record.Path != "" {
+		rec, err := capture.Create(cfg.Record.Path, cfg.Record.Format)
+		if err != nil {
+			log.Fatalf("Помилка запису подій: %v", err)
+		}
+		defer func() {
+			if err := rec.Close(); err != nil {
+				log.Printf("Помилка запису подій у %s: %v", cfg.Record.Path, err)
+			}
+		}()
+		dispatcher.Recorder = func(evt events.EventGetter, raw []byte) {
+			if err := rec.Write(evt, raw); err != nil {
+				log.Printf("Помилка запису подій у %s, запис зупинено: %v", cfg.Record.Path, err)
+			}
+		}
+		log.Printf("Запис подій у %s (%s)", cfg.Record.Path, cfg.Record.Format)
+	}
+	for _, rd := range loaded.Readers {
+		dispatcher.Start(poller.NewRingBufferSource(rd))
+	}
+
+	stopDrops := loaded.WatchDrops(cfg.StatsInterval, reportDrops)
+	defer stopDrops()
+
+	stopSummaries := engine.WatchSuppressed(cfg.SummaryInterval)
+	defer stopSummaries()
+
+	if cfg.MetricsAddr != "" {
+		metrics.Serve(cfg.MetricsAddr)
+		log.Printf("Метрики доступні на %s/metrics", cfg.MetricsAddr)
+	}
 
-	poller.Start(loaded.OpenatReader, engine.HandleOpenat)
-	poller.Start(loaded.ExecveReader, engine.HandleExecve)
-	poller.Start(loaded.ConnectReader, engine.HandleConnect)
-	poller.Start(loaded.AcceptReader, engine.HandleAccept)
-	poller.Start(loaded.PtraceReader, engine.HandlePtrace)
-	poller.Start(loaded.MemfdReader, engine.HandleMemfd)
-	poller.Start(loaded.ChmodReader, engine.HandleChmod)
 	log.Println("Security Monitor запущено")
 
+	reloader := make(chan os.Signal, 1)
+	signal.Notify(reloader, syscall.SIGHUP)
+
 	stopper := make(chan os.Signal, 1)
 	signal.Notify(stopper, os.Interrupt, syscall.SIGTERM)
-	<-stopper
 
-	log.Println("\nЗавершення роботи...")
+	for {
+		select {
+		case <-reloader:
+			if err := reloadRules(cfg, loaded, engine, enforceMode, &denyRules, kernel); err != nil {
+				log.Printf("Помилка перезавантаження правил: %v", err)
+			}
+		case <-stopper:
+			log.Println("\nЗавершення роботи...")
+			return
+		}
+	}
+}
+
+// reloadRules re-reads the rules file and brings probes, kernel filters and
+// the analyzer in line with it. Everything is built and checked before the
+// kernel is touched; if applying fails, the previous kernel state is restored
+// and the old rules stay active. current is updated on success.
+func reloadRules(cfg *config.Config, loaded *loader.LoaderResult, engine *analyzer.Analyzer,
+	enforceMode loader.EnforceMode, denyRules *denyRuleNames, current *kernelRules) error {
+	rulesCfg, err := config.LoadRules(cfg.RulesPath)
+	if err != nil {
+		return err
+	}
+	if err := checkRules(rulesCfg.Rules); err != nil {
+		return err
+	}
+	ioc.Default.Reload()
+	if err := checkFeeds(rulesCfg.Rules); err != nil {
+		return err
+	}
+
+	next, err := buildKernelRules(cfg, enforceMode, rulesCfg.Rules)
+	if err != nil {
+		return err
+	}
+
+	// A failing required probe leaves the attached probes as they were.
+	if err := loaded.SetEnabledEvents(next.events); err != nil {
+		return err
+	}
+	if err := next.applyMaps(loaded, enforceMode, denyRules); err != nil {
+		if rerr := current.apply(loaded, enforceMode, denyRules); rerr != nil {
+			return fmt.Errorf("%v; restoring the previous rules failed: %v", err, rerr)
+		}
+		return fmt.Errorf("%v; previous rules restored", err)
+	}
+	*current = *next
+	reportCapabilities(loaded)
+	engine.SetRules(usableRules(rulesCfg.Rules, loaded))
+
+	log.Printf("Правила перезавантажено: %d правил", len(rulesCfg.Rules))
+	return nil
+}
+
+func checkRules(rules []analyzer.Rule) error {
+	if err := analyzer.CheckRules(rules); err != nil {
+		return err
+	}
+	return response.CheckRules(rules)
+}
+
+// loadFeeds loads the IOC feeds listed in the config into ioc.Default.
+func loadFeeds(cfg *config.Config) error {
+	if err := ioc.Default.Load(cfg.IOC); err != nil {
+		return err
+	}
+	for _, fc := range cfg.IOC.Feeds {
+		feed, _ := ioc.Default.Feed(fc.Name)
+		log.Printf("IOC-фід %s: %d індикаторів з %s", fc.Name, feed.Len(), fc.Path)
+	}
+	return nil
+}
+
+// checkFeeds verifies that every feed named by an in_ioc operator is loaded.
+func checkFeeds(rules []analyzer.Rule) error {
+	for _, name := range analyzer.RequiredFeeds(rules) {
+		if _, ok := ioc.Default.Feed(name); !ok {
+			return fmt.Errorf("IOC feed %q is not configured in ioc.feeds", name)
+		}
+	}
+	return nil
+}
+
+// baselineEvents are the event types profiled by the baseline.
+var baselineEvents = []string{"openat", "execve", "connect"}
+
+// extraEvents lists event types to trace regardless of the rules.
+func extraEvents(cfg *config.Config) []string {
+	extra := cfg.EnabledEvents
+	if cfg.Baseline.Mode != analyzer.BaselineOff {
+		extra = append(slices.Clip(extra), baselineEvents...)
+	}
+	return extra
+}
+
+func selectEvents(rules []analyzer.Rule, extra []string) ([]events.EventType, error) {
+	var res []events.EventType
+	seen := make(map[events.EventType]bool)
+
+	for _, name := range append(analyzer.RequiredEventTypes(rules), extra...) {
+		t, ok := events.ParseEventType(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown event type %q", name)
+		}
+		if !seen[t] {
+			seen[t] = true
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+func reportCapabilities(loaded *loader.LoaderResult) {
+	log.Printf("Доступні події (бекенд %s):", loaded.Backend())
+	for _, st := range loaded.Status() {
+		kind := "optional"
+		if st.Required {
+			kind = "required"
+		}
+		switch {
+		case st.Attached:
+			log.Printf("  %-14s %-12s active via %s (%s)", st.Name, st.Event, st.Backend, kind)
+		case st.Err != nil:
+			log.Printf("  %-14s %-12s unavailable (%s): %v", st.Name, st.Event, kind, st.Err)
+		case !st.Enabled:
+			log.Printf("  %-14s %-12s not needed by rules", st.Name, st.Event)
+		}
+	}
+}
+
+// usableRules drops rules none of whose event types have an attached probe.
+func usableRules(rules []analyzer.Rule, loaded *loader.LoaderResult) []analyzer.Rule {
+	active := loaded.ActiveEvents()
+	usable, disabled := analyzer.SplitRulesByEvents(rules, func(name string) bool {
+		t, ok := events.ParseEventType(name)
+		return ok && active[t]
+	})
+	for _, rule := range disabled {
+		log.Printf("Правило %q вимкнено: події %v недоступні", rule.Name, rule.EventTypes)
+	}
+	return usable
+}
+
+// kernelRules is what a rule set puts into the kernel: the event types whose
+// probes are attached, the openat pre-filter and the deny policy.
+type kernelRules struct {
+	events []events.EventType
+	filter *loader.OpenatFilter // nil if the pre-filter is off
+	policy *loader.DenyPolicy   // nil if enforcement is off
+}
+
+// buildKernelRules prepares the kernel state of the rules without changing
+// anything.
+func buildKernelRules(cfg *config.Config, enforceMode loader.EnforceMode, rules []analyzer.Rule) (*kernelRules, error) {
+	enabled, err := selectEvents(rules, extraEvents(cfg))
+	if err != nil {
+		return nil, err
+	}
+	k := &kernelRules{events: enabled}
+	if cfg.OpenatPrefilter {
+		filter := loader.BuildOpenatFilter(rules)
+		k.filter = &filter
+	}
+	if enforceMode != loader.EnforceOff {
+		policy := loader.BuildDenyPolicy(rules)
+		for name, reason := range policy.Skipped {
+			log.Printf("Правило %q не блокується в ядрі: %s", name, reason)
+		}
+		k.policy = &policy
+	}
+	return k, nil
+}
+
+// apply attaches the probes and fills the maps.
+func (k *kernelRules) apply(loaded *loader.LoaderResult, enforceMode loader.EnforceMode, denyRules *denyRuleNames) error {
+	if err := loaded.SetEnabledEvents(k.events); err != nil {
+		return err
+	}
+	return k.applyMaps(loaded, enforceMode, denyRules)
+}
+
+// applyMaps fills the openat filter and deny maps.
+func (k *kernelRules) applyMaps(loaded *loader.LoaderResult, enforceMode loader.EnforceMode, denyRules *denyRuleNames) error {
+	if k.filter != nil {
+		if err := loaded.ApplyOpenatFilter(*k.filter); err != nil {
+			return fmt.Errorf("openat filter: %w", err)
+		}
+		if k.filter.Enabled {
+			log.Printf("Фільтр openat у ядрі: %d префіксів, %d масок прапорців", len(k.filter.Prefixes), len(k.filter.AnyPath))
+		} else {
+			log.Printf("Фільтр openat у ядрі вимкнено: %s", k.filter.Reason)
+		}
+	}
+
+	if k.policy != nil {
+		// Names first: events of the new policy may arrive before Apply returns.
+		denyRules.names.Store(&k.policy.Rules)
+		if err := loaded.ApplyDenyPolicy(*k.policy, enforceMode); err != nil {
+			return fmt.Errorf("deny policy: %w", err)
+		}
+		log.Printf("Режим блокування %s: %d правил", enforceMode, len(k.policy.Rules))
+	}
+	return nil
+}
+
+// denyRuleNames maps rule ids in deny events to the names of the current
+// deny policy, "" for an unknown id; it is replaced on reload.
+type denyRuleNames struct {
+	names atomic.Pointer[[]string]
+}
+
+func (d *denyRuleNames) name(id uint32) string {
+	if names := d.names.Load(); names != nil && int(id) < len(*names) {
+		return (*names)[id]
+	}
+	return ""
+}
+
+func reportDrops(drops map[events.EventType]loader.DropStats) {
+	for t, d := range drops {
+		metrics.RingBufferDrops.Set(float64(d.RingBuffer), t.String(), "ringbuf")
+		metrics.RingBufferDrops.Set(float64(d.TmpStorage), t.String(), "tmp_storage")
+	}
 }
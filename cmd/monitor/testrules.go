@@ -0,0 +1,78 @@
+package main
+
+import (
+	"diploma/internal/config"
+	"diploma/internal/ruletest"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// runTestRules checks the rules against fixture events and exits non-zero if
+// any case fails.
+func runTestRules(args []string) {
+	fs := flag.NewFlagSet("test-rules", flag.ExitOnError)
+	configPath := fs.String("config", "configs/monitor.yaml", "path to monitor config")
+	rulesPath := fs.String("rules", "", "path to rules (default: rules_path from the config)")
+	verbose := fs.Bool("v", false, "list passing cases too")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monitor test-rules [-config file] [-rules file] [-v] [fixtures...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	fixtures := fs.Args()
+	if len(fixtures) == 0 {
+		fixtures = []string{"configs/rule_tests.yaml"}
+	}
+
+	// The config is needed for its IOC feeds even with -rules.
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Критична помилка: не вдалося завантажити конфігурацію з %s: %v", *configPath, err)
+	}
+	if *rulesPath == "" {
+		*rulesPath = cfg.RulesPath
+	}
+	if err := loadFeeds(cfg); err != nil {
+		log.Fatalf("Помилка завантаження IOC-фідів: %v", err)
+	}
+	rulesCfg, err := config.LoadRules(*rulesPath)
+	if err != nil {
+		log.Fatalf("Критична помилка: не вдалося завантажити правила з %s: %v", *rulesPath, err)
+	}
+	if err := checkRules(rulesCfg.Rules); err != nil {
+		log.Fatalf("Критична помилка: %v", err)
+	}
+	if err := checkFeeds(rulesCfg.Rules); err != nil {
+		log.Fatalf("Критична помилка: %v", err)
+	}
+
+	suite := &ruletest.Suite{}
+	for _, path := range fixtures {
+		s, err := ruletest.Load(path)
+		if err != nil {
+			log.Fatalf("Помилка завантаження фікстур з %s: %v", path, err)
+		}
+		suite.Tests = append(suite.Tests, s.Tests...)
+	}
+
+	var failed int
+	for _, res := range ruletest.Run(rulesCfg.Rules, suite) {
+		switch {
+		case !res.Passed():
+			failed++
+			fmt.Printf("FAIL %s\n", res)
+		case *verbose:
+			fmt.Printf("ok   %s\n", res)
+		}
+	}
+	for _, name := range ruletest.Untested(rulesCfg.Rules, suite) {
+		fmt.Printf("Правило без фікстур: %s\n", name)
+	}
+	fmt.Printf("Перевірено %d випадків, помилок: %d\n", len(suite.Tests), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,37 @@
+package analyzer
+
+import (
+	"diploma/internal/events"
+	"log"
+)
+
+type Alert struct {
+	Rule     string
+	Severity string
+	Message  string
+	ProcName interface{}
+	Pid      interface{}
+	Target   string
+	Event    events.EventGetter
+	// Actions are the response actions of the rule.
+	Actions []string
+}
+
+// Sink delivers alerts to an output. Failed deliveries are counted in metrics.
+// Send may be called from several goroutines at once.
+type Sink interface {
+	Name() string
+	Send(alert Alert) error
+}
+
+type LogSink struct{}
+
+func (LogSink) Name() string {
+	return "log"
+}
+
+func (LogSink) Send(alert Alert) error {
+	log.Printf("[ALERT] %s [%s] | Msg: %s | Proc: %v(%v) | %s",
+		alert.Rule, alert.Severity, alert.Message, alert.ProcName, alert.Pid, alert.Target)
+	return nil
+}
@@ -2,15 +2,34 @@ package analyzer
 
 import (
 	"diploma/internal/events"
+	"diploma/internal/exeinfo"
+	"diploma/internal/metrics"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Analyzer struct {
+	mu    sync.RWMutex
 	Rules []Rule
+	Sinks []Sink
+	// Responders receive every alert, including those suppressed for Sinks,
+	// so that the rules' response actions keep running.
+	Responders []Sink
+	// Offline disables /proc lookups, e.g. when replaying a capture from
+	// another host.
+	Offline bool
+	// Baseline, if set, learns behavior profiles or alerts on deviations.
+	Baseline *Baseline
+	// ExeInfo, if set, describes the files executed by execve events.
+	ExeInfo    *exeinfo.Cache
+	sequences  *sequences
+	thresholds *thresholds
+	suppressor *suppressor
 }
 
 type EnrichedEvent struct {
@@ -19,10 +38,7 @@ type EnrichedEvent struct {
 }
 
 func (e *EnrichedEvent) GetField(name string) (interface{}, bool) {
-	switch name {
-	case "fd.name", "evt.arg.filename":
-		return e.ResolvedPath, true
-	case "proc.exepath":
+	if f, ok := events.LookupField(e.GetType(), name); ok && f.Path {
 		return e.ResolvedPath, true
 	}
 	return e.EventGetter.GetField(name)
@@ -34,57 +50,220 @@ func (e *EnrichedEvent) GetType() string {
 
 func New(rulesCfg RulesConfig) *Analyzer {
 	return &Analyzer{
-		Rules: rulesCfg.Rules,
+		Rules:      rulesCfg.Rules,
+		Sinks:      []Sink{LogSink{}},
+		sequences:  newSequences(rulesCfg.Rules),
+		thresholds: newThresholds(rulesCfg.Rules),
+		suppressor: newSuppressor(rulesCfg.Rules),
 	}
 }
 
+// SetRules replaces the active rule set, e.g. after a reload. Partial
+// sequence matches and threshold counts are dropped; pending suppression
+// summaries are delivered.
+func (a *Analyzer) SetRules(rules []Rule) {
+	a.mu.Lock()
+	a.Rules = rules
+	a.sequences = newSequences(rules)
+	a.thresholds = newThresholds(rules)
+	old := a.suppressor
+	a.suppressor = newSuppressor(rules)
+	a.mu.Unlock()
+
+	a.deliver(old.flush(0, true))
+}
+
 func (a *Analyzer) checkRules(evt events.EventGetter) {
-	for _, rule := range a.Rules {
+	a.mu.RLock()
+	rules := a.Rules
+	seqs := a.sequences
+	thresholds := a.thresholds
+	a.mu.RUnlock()
+
+	if a.Baseline != nil {
+		for _, alert := range a.Baseline.Observe(evt) {
+			a.emit(alert)
+		}
+	}
+
+	for _, alert := range seqs.process(evt) {
+		a.emit(alert)
+	}
+
+	for _, rule := range rules {
+		if !rule.MatchesType(evt.GetType()) {
+			continue
+		}
+		metrics.RuleEvaluations.Inc(rule.Name)
+
 		if rule.CheckEvent(evt) {
+			target := alertTarget(evt)
+			if rule.Threshold != nil {
+				summary, fired := thresholds.observe(&rule, evt)
+				if !fired {
+					continue
+				}
+				target = fmt.Sprintf("%s | last %s", summary, target)
+			}
+			metrics.RuleMatches.Inc(rule.Name)
+
 			procName, _ := evt.GetField("proc.name")
 			pid, _ := evt.GetField("proc.pid")
 
-			var target string
+			a.emit(Alert{
+				Rule:     rule.Name,
+				Severity: rule.Severity,
+				Message:  rule.Message,
+				ProcName: procName,
+				Pid:      pid,
+				Target:   target,
+				Event:    evt,
+				Actions:  rule.Actions,
+			})
+		}
+	}
+}
+
+// alertTarget describes what the event acted on.
+func alertTarget(evt events.EventGetter) string {
+	var target string
 
-			switch evt.GetType() {
-			case "openat", "chmod":
-				if val, ok := evt.GetField("evt.arg.filename"); ok {
-					target = fmt.Sprintf("File: %v", val)
-				}
-			case "execve":
-				if val, ok := evt.GetField("proc.cmdline"); ok {
-					cmd := fmt.Sprintf("%v", val)
-					if len(cmd) > 50 {
-						cmd = cmd[:47] + "..."
-					}
-					target = fmt.Sprintf("Cmd: %s", cmd)
-				}
-			case "connect", "accept":
-				ip, _ := evt.GetField("fd.ip")
-				port, _ := evt.GetField("fd.port")
-				target = fmt.Sprintf("Net: %v:%v", ip, port)
-			case "ptrace":
-				req, _ := evt.GetField("evt.arg.request")
-				tpid, _ := evt.GetField("proc.target_pid")
-				target = fmt.Sprintf("Req: %v -> TargetPid: %v", req, tpid)
-			case "memfd_create":
-				name, _ := evt.GetField("evt.arg.name")
-				target = fmt.Sprintf("MemfdName: %v", name)
+	switch evt.GetType() {
+	case "openat", "chmod":
+		if val, ok := evt.GetField("evt.arg.filename"); ok {
+			target = fmt.Sprintf("File: %v", val)
+		}
+	case "execve":
+		if val, ok := evt.GetField("proc.cmdline"); ok {
+			cmd := fmt.Sprintf("%v", val)
+			if len(cmd) > 50 {
+				cmd = cmd[:47] + "..."
+			}
+			target = fmt.Sprintf("Cmd: %s", cmd)
+		}
+	case "connect", "accept":
+		ip, _ := evt.GetField("fd.ip")
+		port, _ := evt.GetField("fd.port")
+		target = fmt.Sprintf("Net: %v:%v", ip, port)
+	case "ptrace":
+		req, _ := evt.GetField("evt.arg.request")
+		tpid, _ := evt.GetField("proc.target_pid")
+		target = fmt.Sprintf("Req: %v -> TargetPid: %v", req, tpid)
+	case "memfd_create":
+		name, _ := evt.GetField("evt.arg.name")
+		target = fmt.Sprintf("MemfdName: %v", name)
+	}
+	return target
+}
+
+// emit hands an alert to the responders, and to the sinks unless its rule's
+// suppression policy holds it back.
+func (a *Analyzer) emit(alert Alert) {
+	a.sendTo(a.Responders, alert)
+
+	a.mu.RLock()
+	s := a.suppressor
+	a.mu.RUnlock()
+
+	// Without an event time there is no window to count the alert in.
+	now, ok := eventTime(alert.Event)
+	if !ok {
+		a.send(alert)
+		return
+	}
+	ok, summaries := s.allow(alert, now)
+	a.deliver(summaries)
+	if ok {
+		a.send(alert)
+	}
+}
+
+func (a *Analyzer) deliver(alerts []Alert) {
+	for _, alert := range alerts {
+		a.send(alert)
+	}
+}
+
+// WatchSuppressed delivers summaries of ended suppression windows every
+// interval. The returned function stops it.
+func (a *Analyzer) WatchSuppressed(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				a.mu.RLock()
+				s := a.suppressor
+				a.mu.RUnlock()
+				a.deliver(s.flush(monotonicNow(), false))
 			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// FlushSuppressed delivers summaries of all open suppression windows, e.g.
+// before exiting.
+func (a *Analyzer) FlushSuppressed() {
+	a.mu.RLock()
+	s := a.suppressor
+	a.mu.RUnlock()
+	a.deliver(s.flush(0, true))
+}
 
-			log.Printf("[ALERT] %s [%s] | Msg: %s | Proc: %v(%v) | %s",
-				rule.Name, rule.Severity, rule.Message, procName, pid, target)
+func (a *Analyzer) send(alert Alert) {
+	a.sendTo(a.Sinks, alert)
+}
+
+func (a *Analyzer) sendTo(sinks []Sink, alert Alert) {
+	for _, sink := range sinks {
+		if err := sink.Send(alert); err != nil {
+			metrics.AlertSinkFailures.Inc(sink.Name())
+			log.Printf("Alert sink %s error: %v", sink.Name(), err)
 		}
 	}
 }
 
-func (a *Analyzer) HandleOpenat(event events.OpenatEvent) {
-	rawFilename := events.BytesToString(event.Filename[:])
+// Handle routes a decoded event to the handler for its type.
+func (a *Analyzer) Handle(evt events.EventGetter) {
+	switch e := evt.(type) {
+	case *events.OpenatEvent:
+		a.HandleOpenat(e)
+	case *events.ExecveEvent:
+		a.HandleExecve(e)
+	case *events.ConnectEvent:
+		a.HandleConnect(e)
+	case *events.AcceptEvent:
+		a.HandleAccept(e)
+	case *events.PtraceEvent:
+		a.HandlePtrace(e)
+	case *events.MemfdEvent:
+		a.HandleMemfd(e)
+	case *events.ChmodEvent:
+		a.HandleChmod(e)
+	default:
+		a.checkRules(evt)
+	}
+}
 
-	absolutePath := a.resolvePath(event.Common.Pid, event.Ret, rawFilename)
+func (a *Analyzer) HandleOpenat(event *events.OpenatEvent) {
+	// The kernel resolves the path of every file it opened, and for failed
+	// calls the directory of a relative name; /proc is the last resort.
+	absolutePath := event.Path
+	if absolutePath == "" {
+		absolutePath = a.resolvePath(event.Common.Pid, event.Ret, event.Dfd, event.Dir, event.Filename)
+	}
 
 	enrichedEvt := &EnrichedEvent{
-		EventGetter:  &event,
+		EventGetter:  event,
 		ResolvedPath: absolutePath,
 	}
 
@@ -93,54 +272,117 @@ func (a *Analyzer) HandleOpenat(event events.OpenatEvent) {
 	// log.Printf("[OPENAT] File: %s", absolutePath)
 }
 
-func (a *Analyzer) HandleExecve(event events.ExecveEvent) {
-	rawFilename := events.BytesToString(event.Filename[:])
-
-	absolutePath := a.resolvePath(event.Common.Pid, -1, rawFilename)
+func (a *Analyzer) HandleExecve(event *events.ExecveEvent) {
+	absolutePath := a.resolvePath(event.Common.Pid, -1, events.AtFdcwd, "", event.Filename)
 
 	enrichedEvt := &EnrichedEvent{
-		EventGetter:  &event,
+		EventGetter:  event,
 		ResolvedPath: absolutePath,
 	}
 
-	a.checkRules(enrichedEvt)
+	// Replayed files are not ours to read, and captures are written before
+	// the metadata is added: proc.exe.* fields are empty in replay.
+	if a.ExeInfo == nil || a.Offline {
+		a.checkRules(enrichedEvt)
+		return
+	}
+	// A file not seen before is read in the background and the event is
+	// checked once that is done. After a failed execve /proc/<pid>/exe is
+	// still the old program; Describe then reads the path instead.
+	a.ExeInfo.Describe(event.Common.Pid, absolutePath, func(info *events.ExeInfo) {
+		event.Exe = info
+		a.checkRules(enrichedEvt)
+	})
 }
 
-func (a *Analyzer) HandleConnect(event events.ConnectEvent) {
-	a.checkRules(&event)
+func (a *Analyzer) HandleConnect(event *events.ConnectEvent) {
+	a.checkRules(event)
 }
 
-func (a *Analyzer) HandleAccept(event events.AcceptEvent) {
-	a.checkRules(&event)
+func (a *Analyzer) HandleAccept(event *events.AcceptEvent) {
+	a.checkRules(event)
 }
 
-func (a *Analyzer) HandlePtrace(event events.PtraceEvent) {
-	a.checkRules(&event)
+func (a *Analyzer) HandlePtrace(event *events.PtraceEvent) {
+	a.checkRules(event)
 }
 
-func (a *Analyzer) HandleMemfd(event events.MemfdEvent) {
+func (a *Analyzer) HandleMemfd(event *events.MemfdEvent) {
 	// name := events.BytesToString(event.Name[:])
 	// log.Printf("[DEBUG] MEMFD_CREATE: Pid=%d Name='%s' Flags=%d RetFD=%d",
 	// 	event.Common.Pid, name, event.Flags, event.Ret)
-	a.checkRules(&event)
+	a.checkRules(event)
 }
 
-func (a *Analyzer) HandleChmod(event events.ChmodEvent) {
-	rawFilename := events.BytesToString(event.Filename[:])
-
-	absolutePath := a.resolvePath(event.Common.Pid, -1, rawFilename)
+func (a *Analyzer) HandleChmod(event *events.ChmodEvent) {
+	absolutePath := a.resolvePath(event.Common.Pid, -1, event.Dfd, event.Dir, event.Filename)
 
 	// log.Printf("[DEBUG] CHMOD: Pid=%d File=%s Mode=0%o",
 	// 	event.Common.Pid, absolutePath, event.Mode)
 
 	enrichedEvt := &EnrichedEvent{
-		EventGetter:  &event,
+		EventGetter:  event,
 		ResolvedPath: absolutePath,
 	}
 	a.checkRules(enrichedEvt)
 }
 
-func (a *Analyzer) resolvePath(pid uint32, fd int32, filename string) string {
+// HandleDeny reports an operation matched by a deny rule in the kernel. The
+// rule is looked up by event.RuleName for its severity and message.
+func (a *Analyzer) HandleDeny(event *events.DenyEvent) {
+	a.mu.RLock()
+	rules := a.Rules
+	a.mu.RUnlock()
+
+	ruleName := event.RuleName
+	if ruleName == "" {
+		ruleName = fmt.Sprintf("deny rule #%d", event.RuleId)
+	}
+
+	alert := Alert{
+		Rule:     ruleName,
+		ProcName: events.BytesToString(event.Common.Comm[:]),
+		Pid:      int(event.Common.Pid),
+		Event:    event,
+	}
+	for _, rule := range rules {
+		if rule.Name == ruleName {
+			alert.Severity = rule.Severity
+			alert.Message = rule.Message
+			break
+		}
+	}
+
+	verdict := "BLOCKED"
+	if event.Blocked == 0 {
+		verdict = "WOULD BLOCK (dry run)"
+	}
+	switch event.Hook {
+	case events.DenyHookSocketConnect:
+		ip, _ := event.GetField("fd.ip")
+		port, _ := event.GetField("fd.port")
+		alert.Target = fmt.Sprintf("%s %s: Net: %v:%v", verdict, event.HookName(), ip, port)
+	case events.DenyHookPtrace:
+		alert.Target = fmt.Sprintf("%s %s: TargetPid: %d", verdict, event.HookName(), event.TargetPid)
+	default:
+		alert.Target = fmt.Sprintf("%s %s: File: %s", verdict, event.HookName(), event.Path)
+	}
+
+	a.emit(alert)
+}
+
+// resolvePath makes filename absolute. fd is the descriptor it was opened
+// as, if any. A relative name is joined with dir, its directory resolved by
+// the kernel, or else with the directory open as dfd (the working directory
+// for AT_FDCWD) looked up in /proc while the process still exists.
+func (a *Analyzer) resolvePath(pid uint32, fd, dfd int32, dir, filename string) string {
+	if a.Offline {
+		if strings.HasPrefix(filename, "/") || dir != "" {
+			return events.JoinDir(dir, filename)
+		}
+		return fmt.Sprintf("UNKNOWN/%s", filename)
+	}
+
 	if fd >= 0 {
 		linkPath := fmt.Sprintf("/proc/%d/fd/%d", pid, fd)
 		if realPath, err := os.Readlink(linkPath); err == nil {
@@ -148,13 +390,16 @@ func (a *Analyzer) resolvePath(pid uint32, fd int32, filename string) string {
 		}
 	}
 
-	if strings.HasPrefix(filename, "/") {
-		return filename
+	if strings.HasPrefix(filename, "/") || dir != "" {
+		return events.JoinDir(dir, filename)
 	}
 
-	cwdLink := fmt.Sprintf("/proc/%d/cwd", pid)
-	if cwd, err := os.Readlink(cwdLink); err == nil {
-		return filepath.Join(cwd, filename)
+	dirLink := fmt.Sprintf("/proc/%d/cwd", pid)
+	if dfd != events.AtFdcwd {
+		dirLink = fmt.Sprintf("/proc/%d/fd/%d", pid, dfd)
+	}
+	if base, err := os.Readlink(dirLink); err == nil {
+		return filepath.Join(base, filename)
 	}
 
 	return fmt.Sprintf("UNKNOWN/%s", filename)
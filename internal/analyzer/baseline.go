@@ -0,0 +1,452 @@
+package analyzer
+
+import (
+	"bytes"
+	"diploma/internal/events"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BaselineOff    = "off"
+	BaselineLearn  = "learn"
+	BaselineDetect = "detect"
+
+	BaselineScopeExe    = "exe"
+	BaselineScopeCgroup = "cgroup"
+
+	BaselineRule = "Baseline Deviation"
+
+	// maxExeCache bounds the pid to executable cache; it is cleared when full.
+	maxExeCache = 65536
+)
+
+// BaselineConfig controls behavioral profiling. In learn mode profiles of
+// opened paths, executed children and network destinations are recorded for
+// LearnPeriod, saved to Path and then used for detection; detect mode loads
+// them from Path and alerts on anything not seen while learning.
+type BaselineConfig struct {
+	Mode        string        `yaml:"mode"`
+	Path        string        `yaml:"path"`
+	LearnPeriod time.Duration `yaml:"learn_period"`
+	// Scope keys profiles by executable path (exe) or by cgroup (cgroup).
+	Scope string `yaml:"scope"`
+	// MaxEntries caps each set of a profile. A set that overflowed while
+	// learning is too varied to be useful and is not checked. In detect mode
+	// it also caps the values remembered as reported.
+	MaxEntries int    `yaml:"max_entries"`
+	Severity   string `yaml:"severity"`
+}
+
+// Profile is what one executable or cgroup was seen doing while learning.
+type Profile struct {
+	Paths    []string `json:"paths"`
+	Children []string `json:"children"`
+	Network  []string `json:"network"`
+	// Saturated lists the sets that hit MaxEntries.
+	Saturated []string `json:"saturated,omitempty"`
+}
+
+type baselineFile struct {
+	Scope     string              `json:"scope"`
+	LearnedAt time.Time           `json:"learned_at"`
+	Complete  bool                `json:"complete"`
+	Profiles  map[string]*Profile `json:"profiles"`
+}
+
+const (
+	setPaths    = "paths"
+	setChildren = "children"
+	setNetwork  = "network"
+)
+
+type profile struct {
+	sets      map[string]map[string]bool
+	saturated map[string]bool
+	// alerted holds the values reported in detect mode; each set is cleared
+	// when it reaches MaxEntries.
+	alerted map[string]map[string]bool
+	// reported marks an unknown profile as already alerted in detect mode.
+	reported bool
+}
+
+func newProfile() *profile {
+	return &profile{
+		sets: map[string]map[string]bool{
+			setPaths:    {},
+			setChildren: {},
+			setNetwork:  {},
+		},
+		saturated: make(map[string]bool),
+		alerted: map[string]map[string]bool{
+			setPaths:    {},
+			setChildren: {},
+			setNetwork:  {},
+		},
+	}
+}
+
+// Baseline learns per-executable or per-cgroup profiles and reports
+// deviations from them.
+type Baseline struct {
+	cfg      BaselineConfig
+	mu       sync.Mutex
+	learning bool
+	deadline time.Time
+	profiles map[string]*profile
+	exes     map[uint32]exeEntry
+}
+
+// exeEntry is the cached executable of a pid. A reused pid belongs to a
+// process with another start time, so its entry is not used.
+type exeEntry struct {
+	path string
+	// start is the process start time from /proc/<pid>/stat, 0 if unknown.
+	start uint64
+}
+
+func CheckBaselineConfig(cfg BaselineConfig) error {
+	switch cfg.Mode {
+	case BaselineOff, BaselineLearn, BaselineDetect:
+	default:
+		return fmt.Errorf("unknown baseline mode %q", cfg.Mode)
+	}
+	switch cfg.Scope {
+	case BaselineScopeExe, BaselineScopeCgroup:
+	default:
+		return fmt.Errorf("unknown baseline scope %q", cfg.Scope)
+	}
+	if cfg.Path == "" {
+		return fmt.Errorf("baseline path is empty")
+	}
+	if cfg.MaxEntries <= 0 {
+		return fmt.Errorf("baseline max_entries must be positive")
+	}
+	return nil
+}
+
+// NewBaseline loads the saved profiles. In learn mode a missing file starts
+// an empty baseline and an incomplete one is extended; in detect mode the
+// file must exist.
+func NewBaseline(cfg BaselineConfig) (*Baseline, error) {
+	if err := CheckBaselineConfig(cfg); err != nil {
+		return nil, err
+	}
+	b := &Baseline{
+		cfg:      cfg,
+		learning: cfg.Mode == BaselineLearn,
+		profiles: make(map[string]*profile),
+		exes:     make(map[uint32]exeEntry),
+	}
+
+	saved, err := b.load()
+	switch {
+	case err == nil:
+		if saved.Scope != cfg.Scope {
+			return nil, fmt.Errorf("baseline %s was learned with scope %q, not %q", cfg.Path, saved.Scope, cfg.Scope)
+		}
+		if b.learning && saved.Complete {
+			return nil, fmt.Errorf("baseline %s is already complete; remove it to learn again", cfg.Path)
+		}
+	case os.IsNotExist(err) && b.learning:
+	default:
+		return nil, fmt.Errorf("failed to load baseline: %w", err)
+	}
+
+	if b.learning {
+		b.deadline = time.Now().Add(cfg.LearnPeriod)
+	}
+	return b, nil
+}
+
+// Learning reports whether profiles are still being recorded.
+func (b *Baseline) Learning() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.learning
+}
+
+// Observe records the event while learning and returns alerts for
+// deviations afterwards. Events should carry resolved paths.
+func (b *Baseline) Observe(evt events.EventGetter) []Alert {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.learning && !time.Now().Before(b.deadline) {
+		b.finishLocked()
+	}
+
+	key, set, value, ok := b.classify(evt)
+	if !ok {
+		return nil
+	}
+
+	p := b.profiles[key]
+	if b.learning {
+		if p == nil {
+			p = newProfile()
+			b.profiles[key] = p
+		}
+		if p.saturated[set] {
+			return nil
+		}
+		if len(p.sets[set]) >= b.cfg.MaxEntries {
+			p.saturated[set] = true
+			p.sets[set] = map[string]bool{}
+			return nil
+		}
+		p.sets[set][value] = true
+		return nil
+	}
+
+	if p == nil {
+		p = newProfile()
+		b.profiles[key] = p
+		p.reported = true
+		return []Alert{b.alert(evt, fmt.Sprintf("no learned profile for %s %s", b.cfg.Scope, key), "")}
+	}
+	if p.reported || p.saturated[set] || p.sets[set][value] || p.alerted[set][value] {
+		return nil
+	}
+	// Report each new value once, as long as it is remembered.
+	if len(p.alerted[set]) >= b.cfg.MaxEntries {
+		clear(p.alerted[set])
+	}
+	p.alerted[set][value] = true
+	what := map[string]string{
+		setPaths:    "unlearned file opened",
+		setChildren: "unlearned child executed",
+		setNetwork:  "unlearned network destination",
+	}[set]
+	return []Alert{b.alert(evt, fmt.Sprintf("%s by %s %s", what, b.cfg.Scope, key), value)}
+}
+
+func (b *Baseline) alert(evt events.EventGetter, msg, value string) Alert {
+	procName, _ := evt.GetField("proc.name")
+	pid, _ := evt.GetField("proc.pid")
+	target := alertTarget(evt)
+	if value != "" {
+		target = fmt.Sprintf("%s | %s", value, target)
+	}
+	return Alert{
+		Rule:     BaselineRule,
+		Severity: b.cfg.Severity,
+		Message:  msg,
+		ProcName: procName,
+		Pid:      pid,
+		Target:   target,
+		Event:    evt,
+	}
+}
+
+// classify maps an event to its profile key, the set it belongs to and the
+// recorded value.
+func (b *Baseline) classify(evt events.EventGetter) (key, set, value string, ok bool) {
+	pidVal, _ := evt.GetField("proc.pid")
+	pid, _ := pidVal.(int)
+
+	switch evt.GetType() {
+	case "openat":
+		res, _ := evt.GetField("evt.res")
+		if r, _ := res.(int); r < 0 {
+			return "", "", "", false
+		}
+		path, _ := evt.GetField("fd.name")
+		key, ok = b.profileKey(evt, uint32(pid))
+		return key, setPaths, normalizePath(fmt.Sprint(path)), ok
+	case "execve":
+		res, _ := evt.GetField("evt.res")
+		if r, _ := res.(int); r < 0 {
+			return "", "", "", false
+		}
+		exe, _ := evt.GetField("proc.exepath")
+		child := fmt.Sprint(exe)
+		// The parent's profile owns the child, so look it up first.
+		ppidVal, _ := evt.GetField("proc.ppid")
+		ppid, _ := ppidVal.(int)
+		if b.cfg.Scope == BaselineScopeExe {
+			key, ok = b.exe(uint32(ppid))
+		} else {
+			key, ok = b.profileKey(evt, uint32(ppid))
+		}
+		start, _ := procStartTime(uint32(pid))
+		b.cacheExe(uint32(pid), child, start)
+		return key, setChildren, child, ok
+	case "connect":
+		ip, _ := evt.GetField("fd.ip")
+		port, _ := evt.GetField("fd.port")
+		key, ok = b.profileKey(evt, uint32(pid))
+		return key, setNetwork, fmt.Sprintf("%v:%v", ip, port), ok
+	}
+	return "", "", "", false
+}
+
+func (b *Baseline) profileKey(evt events.EventGetter, pid uint32) (string, bool) {
+	if b.cfg.Scope == BaselineScopeCgroup {
+		id, ok := evt.GetField("proc.cgroup")
+		return fmt.Sprint(id), ok
+	}
+	return b.exe(pid)
+}
+
+// exe returns the executable of a process. An entry cached for the pid is
+// used if the process has its start time, or has exited since: its events
+// may still be queued.
+func (b *Baseline) exe(pid uint32) (string, bool) {
+	start, alive := procStartTime(pid)
+	if e, ok := b.exes[pid]; ok && (!alive || e.start == start) {
+		return e.path, true
+	}
+	if !alive {
+		return "", false
+	}
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return "", false
+	}
+	b.cacheExe(pid, exe, start)
+	return exe, true
+}
+
+func (b *Baseline) cacheExe(pid uint32, exe string, start uint64) {
+	if len(b.exes) >= maxExeCache {
+		clear(b.exes)
+	}
+	b.exes[pid] = exeEntry{path: exe, start: start}
+}
+
+// procStartTime reads the start time of a process in clock ticks since boot.
+func procStartTime(pid uint32) (uint64, bool) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, false
+	}
+	// The command name may contain spaces; the fields after it do not.
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return 0, false
+	}
+	// starttime is field 22 of stat, state (field 3) is the first after comm.
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return 0, false
+	}
+	start, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
+
+// normalizePath replaces numeric components such as pids and fd numbers so
+// that /proc/1234/status and /proc/5678/status count as one path.
+func normalizePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if part != "" && strings.Trim(part, "0123456789") == "" {
+			parts[i] = "*"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (b *Baseline) finishLocked() {
+	b.learning = false
+	if err := b.saveLocked(true); err != nil {
+		log.Printf("Baseline save error: %v", err)
+	}
+	log.Printf("Baseline learned: %d profiles saved to %s, detection started", len(b.profiles), b.cfg.Path)
+}
+
+// Close saves the profiles if learning has not finished yet.
+func (b *Baseline) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.learning {
+		return nil
+	}
+	return b.saveLocked(false)
+}
+
+func (b *Baseline) load() (*baselineFile, error) {
+	data, err := os.ReadFile(b.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	var f baselineFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", b.cfg.Path, err)
+	}
+	for key, saved := range f.Profiles {
+		p := newProfile()
+		for set, values := range map[string][]string{
+			setPaths:    saved.Paths,
+			setChildren: saved.Children,
+			setNetwork:  saved.Network,
+		} {
+			for _, v := range values {
+				p.sets[set][v] = true
+			}
+		}
+		for _, set := range saved.Saturated {
+			p.saturated[set] = true
+		}
+		b.profiles[key] = p
+	}
+	return &f, nil
+}
+
+// saveLocked writes the profiles through a temporary file so that a crash
+// never leaves a truncated baseline.
+func (b *Baseline) saveLocked(complete bool) error {
+	f := baselineFile{
+		Scope:     b.cfg.Scope,
+		LearnedAt: time.Now(),
+		Complete:  complete,
+		Profiles:  make(map[string]*Profile, len(b.profiles)),
+	}
+	for key, p := range b.profiles {
+		saved := &Profile{
+			Paths:    sortedKeys(p.sets[setPaths]),
+			Children: sortedKeys(p.sets[setChildren]),
+			Network:  sortedKeys(p.sets[setNetwork]),
+		}
+		saved.Saturated = sortedKeys(p.saturated)
+		f.Profiles[key] = saved
+	}
+
+	data, err := json.MarshalIndent(&f, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(b.cfg.Path), ".baseline-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), b.cfg.Path)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,142 @@
+package analyzer
+
+import (
+	"diploma/internal/events"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func connect(ts int64, pid uint32, ip uint32, port uint16) *events.ConnectEvent {
+	return &events.ConnectEvent{Common: common(events.EventConnect, ts, pid), Ip: ip, Port: port<<8 | port>>8}
+}
+
+func TestBaseline(t *testing.T) {
+	learned := []events.EventGetter{
+		openat(0, 1, "/etc/hosts"),
+		openat(0, 2, "/proc/123/status"),
+		execve(0, 3, "/usr/bin/id"),
+		connect(0, 1, 0x0100007f, 80),
+	}
+
+	tests := []struct {
+		name       string
+		maxEntries int
+		events     []events.EventGetter
+		want       []bool // whether each event is reported
+	}{
+		{
+			name:   "learned behavior",
+			events: []events.EventGetter{openat(0, 4, "/etc/hosts"), openat(0, 4, "/proc/999/status"), execve(0, 5, "/usr/bin/id"), connect(0, 4, 0x0100007f, 80)},
+			want:   []bool{false, false, false, false},
+		},
+		{
+			name:   "new values reported once",
+			events: []events.EventGetter{openat(0, 4, "/etc/shadow"), openat(0, 4, "/etc/shadow"), execve(0, 5, "/bin/nc"), connect(0, 4, 0x0100007f, 443)},
+			want:   []bool{true, false, true, true},
+		},
+		{
+			name:       "saturated set not checked",
+			maxEntries: 1,
+			events:     []events.EventGetter{openat(0, 4, "/etc/shadow"), connect(0, 4, 0x0100007f, 443)},
+			want:       []bool{false, true},
+		},
+		{
+			name:       "reported values bounded by max_entries",
+			maxEntries: 3,
+			events: []events.EventGetter{
+				connect(0, 4, 0x0100007f, 1),
+				connect(0, 4, 0x0100007f, 2),
+				connect(0, 4, 0x0100007f, 3),
+				connect(0, 4, 0x0100007f, 1),
+				// The reported values were full and are forgotten.
+				connect(0, 4, 0x0100007f, 4),
+				connect(0, 4, 0x0100007f, 1),
+			},
+			want: []bool{true, true, true, false, true, true},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := BaselineConfig{
+				Mode:        BaselineLearn,
+				Path:        filepath.Join(t.TempDir(), "baseline.json"),
+				LearnPeriod: time.Hour,
+				Scope:       BaselineScopeCgroup,
+				MaxEntries:  tc.maxEntries,
+			}
+			if cfg.MaxEntries == 0 {
+				cfg.MaxEntries = 100
+			}
+			b, err := NewBaseline(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, evt := range learned {
+				if alerts := b.Observe(evt); len(alerts) != 0 {
+					t.Fatalf("alert while learning: %+v", alerts)
+				}
+			}
+			b.mu.Lock()
+			b.finishLocked()
+			b.mu.Unlock()
+
+			// Detection runs on the saved profiles.
+			cfg.Mode = BaselineDetect
+			if b, err = NewBaseline(cfg); err != nil {
+				t.Fatal(err)
+			}
+			for i, evt := range tc.events {
+				if got := len(b.Observe(evt)) > 0; got != tc.want[i] {
+					t.Errorf("event %d reported = %v, want %v", i, got, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBaselineUnknownProfile(t *testing.T) {
+	cfg := BaselineConfig{
+		Mode:        BaselineLearn,
+		Path:        filepath.Join(t.TempDir(), "baseline.json"),
+		LearnPeriod: time.Hour,
+		Scope:       BaselineScopeCgroup,
+		MaxEntries:  10,
+	}
+	b, err := NewBaseline(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Observe(openat(0, 1, "/etc/hosts"))
+	b.mu.Lock()
+	b.finishLocked()
+	b.mu.Unlock()
+
+	other := openat(0, 1, "/etc/hosts")
+	other.Common.CgroupId = 78
+	if alerts := b.Observe(other); len(alerts) != 1 {
+		t.Fatalf("unknown cgroup: %d alerts, want 1", len(alerts))
+	}
+	if alerts := b.Observe(openat(0, 1, "/etc/shadow")); len(alerts) != 1 {
+		t.Errorf("known cgroup: %d alerts, want 1", len(alerts))
+	}
+	other.Common.Header.TimestampNs++
+	if alerts := b.Observe(other); len(alerts) != 0 {
+		t.Errorf("unknown cgroup reported again: %+v", alerts)
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := map[string]string{
+		"/proc/1234/status": "/proc/*/status",
+		"/dev/pts/3":        "/dev/pts/*",
+		"/usr/lib64":        "/usr/lib64",
+		"/":                 "/",
+	}
+	for in, want := range tests {
+		if got := normalizePath(in); got != want {
+			t.Errorf("normalizePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
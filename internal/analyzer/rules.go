@@ -2,7 +2,9 @@ package analyzer
 
 import (
 	"diploma/internal/events"
+	"diploma/internal/ioc"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -19,21 +21,226 @@ type Rule struct {
 	Conditions []Condition `yaml:"conditions"`
 	Severity   string      `yaml:"severity"`
 	Message    string      `yaml:"message"`
+	// Action is empty (alert only) or "deny" to block matching operations
+	// in the kernel when enforcement is enabled.
+	Action string `yaml:"action"`
+	// Actions are response actions run by the response sink when the rule
+	// fires, e.g. kill or freeze_cgroup.
+	Actions []string `yaml:"actions"`
+	// Sequence makes this a stateful rule; EventTypes and Conditions are then
+	// taken from its steps.
+	Sequence *Sequence `yaml:"sequence"`
+	// Threshold makes the rule fire only when it matches often enough.
+	Threshold *Threshold `yaml:"threshold"`
+	// Suppress limits repeated alerts of the rule.
+	Suppress *Suppression `yaml:"suppress"`
 }
 
+const ActionDeny = "deny"
+
 type RulesConfig struct {
 	Rules []Rule `yaml:"rules"`
 }
 
-func (r *Rule) CheckEvent(evt events.EventGetter) bool {
-	matchType := false
+// CheckRules validates the rules: fields and operators against the event
+// types they apply to, and the sequence, threshold and suppression parts.
+func CheckRules(rules []Rule) error {
+	for _, rule := range rules {
+		if rule.Suppress != nil {
+			if err := rule.Suppress.validate(); err != nil {
+				return fmt.Errorf("rule %q: %v", rule.Name, err)
+			}
+		}
+		if rule.Threshold != nil {
+			if rule.Sequence != nil {
+				return fmt.Errorf("rule %q: sequence and threshold cannot be combined", rule.Name)
+			}
+			if err := rule.Threshold.validate(); err != nil {
+				return fmt.Errorf("rule %q: %v", rule.Name, err)
+			}
+		}
+		if rule.Sequence == nil {
+			continue
+		}
+		if len(rule.EventTypes) > 0 || len(rule.Conditions) > 0 {
+			return fmt.Errorf("rule %q: sequence rules take event types and conditions from their steps", rule.Name)
+		}
+		if err := rule.Sequence.validate(); err != nil {
+			return fmt.Errorf("rule %q: %v", rule.Name, err)
+		}
+	}
+	for _, rule := range rules {
+		if err := rule.checkFields(); err != nil {
+			return fmt.Errorf("rule %q: %v", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// numericOperators compare fields as integers.
+var numericOperators = map[string]bool{"lt": true, "mt": true}
+
+var operators = map[string]bool{
+	"=": true, "!=": true, "lt": true, "mt": true,
+	"startswith": true, "contains": true, "in": true, "not in": true,
+}
+
+// iocOperator prefixes the operator that looks a field up in the IOC feed
+// named after it, e.g. in_ioc:tor_exits. The condition's value is unused.
+const iocOperator = "in_ioc:"
+
+func iocFeed(operator string) (string, bool) {
+	feed, ok := strings.CutPrefix(operator, iocOperator)
+	return feed, ok && feed != ""
+}
+
+// checkFields verifies that every field the rule reads exists for each event
+// type it can be evaluated on.
+func (r *Rule) checkFields() error {
+	var allTypes []string
+	for i, part := range r.EventRules() {
+		where := ""
+		if r.Sequence != nil {
+			where = fmt.Sprintf("step %d: ", i+1)
+		}
+		for _, t := range part.EventTypes {
+			if _, ok := events.ParseEventType(t); !ok {
+				return fmt.Errorf("%sunknown event type %q", where, t)
+			}
+			for _, cond := range part.Conditions {
+				_, isIOC := iocFeed(cond.Operator)
+				if !operators[cond.Operator] && !isIOC {
+					return fmt.Errorf("%sunknown operator %q", where, cond.Operator)
+				}
+				f, ok := events.LookupField(t, cond.Field)
+				if !ok {
+					return fmt.Errorf("%sno field %s on %s events", where, cond.Field, t)
+				}
+				if isIOC && f.Type != events.FieldString {
+					return fmt.Errorf("%soperator %s needs a string field, %s is %s", where, cond.Operator, cond.Field, f.Type)
+				}
+				if numericOperators[cond.Operator] && f.Type != events.FieldInt {
+					return fmt.Errorf("%soperator %s needs a numeric field, %s is %s", where, cond.Operator, cond.Field, f.Type)
+				}
+			}
+			if r.Sequence != nil {
+				if err := checkFieldNames(t, r.Sequence.keyFields(r.Sequence.Steps[i], t)); err != nil {
+					return fmt.Errorf("%sjoin key: %v", where, err)
+				}
+			}
+			allTypes = append(allTypes, t)
+		}
+	}
+
+	for _, t := range allTypes {
+		if r.Threshold != nil {
+			fields := r.Threshold.GroupBy
+			if r.Threshold.Distinct != "" {
+				fields = append(slices.Clip(fields), r.Threshold.Distinct)
+			}
+			if err := checkFieldNames(t, fields); err != nil {
+				return fmt.Errorf("threshold: %v", err)
+			}
+		}
+		if r.Suppress != nil {
+			if err := checkFieldNames(t, r.Suppress.Key); err != nil {
+				return fmt.Errorf("suppress key: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+func checkFieldNames(eventType string, names []string) error {
+	for _, name := range names {
+		if _, ok := events.LookupField(eventType, name); !ok {
+			return fmt.Errorf("no field %s on %s events", name, eventType)
+		}
+	}
+	return nil
+}
+
+// EventRules returns the single-event parts of the rule: the rule itself, or
+// one rule per step of a sequence.
+func (r *Rule) EventRules() []Rule {
+	if r.Sequence == nil {
+		return []Rule{*r}
+	}
+	res := make([]Rule, len(r.Sequence.Steps))
+	for i, step := range r.Sequence.Steps {
+		res[i] = Rule{
+			Name:       r.Name,
+			EventTypes: step.EventTypes,
+			Conditions: step.Conditions,
+		}
+	}
+	return res
+}
+
+func (r *Rule) MatchesType(eventType string) bool {
 	for _, t := range r.EventTypes {
-		if t == evt.GetType() {
-			matchType = true
-			break
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiredEventTypes returns the event types referenced by at least one rule.
+func RequiredEventTypes(rules []Rule) []string {
+	seen := make(map[string]bool)
+	var res []string
+	for _, rule := range rules {
+		for _, part := range rule.EventRules() {
+			for _, t := range part.EventTypes {
+				if !seen[t] {
+					seen[t] = true
+					res = append(res, t)
+				}
+			}
 		}
 	}
-	if !matchType {
+	return res
+}
+
+// RequiredFeeds returns the IOC feeds referenced by at least one rule.
+func RequiredFeeds(rules []Rule) []string {
+	var res []string
+	for _, rule := range rules {
+		for _, part := range rule.EventRules() {
+			for _, cond := range part.Conditions {
+				if feed, ok := iocFeed(cond.Operator); ok && !slices.Contains(res, feed) {
+					res = append(res, feed)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// SplitRulesByEvents separates rules that can still fire with the given event
+// types active from those that cannot. Rules missing only some of their
+// event types stay usable. A sequence needs every step to stay usable.
+func SplitRulesByEvents(rules []Rule, active func(eventType string) bool) (usable, disabled []Rule) {
+	for _, rule := range rules {
+		ok := true
+		for _, part := range rule.EventRules() {
+			if !slices.ContainsFunc(part.EventTypes, active) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			usable = append(usable, rule)
+		} else {
+			disabled = append(disabled, rule)
+		}
+	}
+	return usable, disabled
+}
+
+func (r *Rule) CheckEvent(evt events.EventGetter) bool {
+	if !r.MatchesType(evt.GetType()) {
 		return false
 	}
 
@@ -53,6 +260,10 @@ func (r *Rule) CheckEvent(evt events.EventGetter) bool {
 func checkCondition(fieldVal interface{}, operator, ruleVal string) bool {
 	strVal := fmt.Sprintf("%v", fieldVal)
 
+	if feed, ok := iocFeed(operator); ok {
+		return ioc.Default.Contains(feed, strVal)
+	}
+
 	switch operator {
 	case "=":
 		return strVal == ruleVal
@@ -0,0 +1,275 @@
+package analyzer
+
+import (
+	"container/list"
+	"diploma/internal/events"
+	"diploma/internal/metrics"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultSequenceMaxState = 4096
+
+// Sequence is a rule matching several events in order. The events of one
+// match must agree on their join key, and the last step must happen within
+// Window of the first. Steps are taken in the order the analyzer checks the
+// events: with exe_info enabled, an execve of a file not hashed before is
+// checked once its metadata is read, possibly after later events of the same
+// process, so a step following such an execve may be missed.
+type Sequence struct {
+	Window time.Duration `yaml:"window"`
+	// Join lists the key shared by all steps: pid, cgroup, path, or any
+	// field name. Steps may override it with their own Key.
+	Join []string `yaml:"join"`
+	// MaxState bounds the number of partial matches kept for the rule; the
+	// oldest one is dropped when it is reached.
+	MaxState int    `yaml:"max_state"`
+	Steps    []Step `yaml:"steps"`
+}
+
+type Step struct {
+	EventTypes []string    `yaml:"event_types"`
+	Conditions []Condition `yaml:"conditions"`
+	// Key lists the fields compared with the join key of the first step.
+	Key []string `yaml:"key"`
+}
+
+// joinAliases maps join shorthands to fields. path depends on the event type.
+var joinAliases = map[string]string{
+	"pid":    "proc.pid",
+	"cgroup": "proc.cgroup",
+}
+
+var pathFields = map[string]string{
+	"openat":       "fd.name",
+	"chmod":        "fd.name",
+	"execve":       "proc.exepath",
+	"memfd_create": "evt.arg.name",
+}
+
+func (s *Sequence) validate() error {
+	if len(s.Steps) < 2 {
+		return fmt.Errorf("sequence needs at least two steps")
+	}
+	if s.Window <= 0 {
+		return fmt.Errorf("sequence needs a positive window")
+	}
+	keyLen := -1
+	for i, step := range s.Steps {
+		if len(step.EventTypes) == 0 {
+			return fmt.Errorf("step %d has no event types", i+1)
+		}
+		for _, t := range step.EventTypes {
+			n := len(s.keyFields(step, t))
+			if n == 0 {
+				return fmt.Errorf("step %d has no join key", i+1)
+			}
+			if keyLen >= 0 && n != keyLen {
+				return fmt.Errorf("step %d has %d key fields, want %d", i+1, n, keyLen)
+			}
+			keyLen = n
+		}
+		for _, j := range s.Join {
+			if j == "path" && len(step.Key) == 0 {
+				for _, t := range step.EventTypes {
+					if pathFields[t] == "" {
+						return fmt.Errorf("step %d: no path field for %s events", i+1, t)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Sequence) keyFields(step Step, eventType string) []string {
+	if len(step.Key) > 0 {
+		return step.Key
+	}
+	res := make([]string, 0, len(s.Join))
+	for _, j := range s.Join {
+		switch {
+		case j == "path":
+			res = append(res, pathFields[eventType])
+		case joinAliases[j] != "":
+			res = append(res, joinAliases[j])
+		default:
+			res = append(res, j)
+		}
+	}
+	return res
+}
+
+// sequenceMatcher keeps the partial matches of one sequence rule, oldest
+// first in order so expired ones can be dropped from the front.
+type sequenceMatcher struct {
+	rule     Rule
+	steps    []Rule
+	partials map[string]*list.Element
+	order    *list.List
+}
+
+type partial struct {
+	key     string
+	next    int   // index of the step waiting to match
+	start   int64 // event time of the first step, ns
+	targets []string
+}
+
+func newSequenceMatcher(rule Rule) *sequenceMatcher {
+	return &sequenceMatcher{
+		rule:     rule,
+		steps:    rule.EventRules(),
+		partials: make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// process feeds one event to the matcher and returns the alert of a
+// completed sequence, if any.
+func (m *sequenceMatcher) process(evt events.EventGetter) *Alert {
+	seq := m.rule.Sequence
+	now, ok := eventTime(evt)
+	if !ok {
+		return nil
+	}
+	m.expire(now - int64(seq.Window))
+
+	// Later steps first, so one event cannot complete two steps at once.
+	for i := len(m.steps) - 1; i >= 0; i-- {
+		step := m.steps[i]
+		if !step.MatchesType(evt.GetType()) {
+			continue
+		}
+		metrics.RuleEvaluations.Inc(m.rule.Name)
+		if !step.CheckEvent(evt) {
+			continue
+		}
+		key, ok := joinKey(evt, seq.keyFields(seq.Steps[i], evt.GetType()))
+		if !ok {
+			continue
+		}
+
+		if i == 0 {
+			m.start(key, now, alertTarget(evt))
+			continue
+		}
+
+		el := m.partials[key]
+		if el == nil || el.Value.(*partial).next != i {
+			continue
+		}
+		p := el.Value.(*partial)
+		p.targets = append(p.targets, alertTarget(evt))
+		if i < len(m.steps)-1 {
+			p.next++
+			return nil
+		}
+
+		m.remove(el)
+		metrics.RuleMatches.Inc(m.rule.Name)
+		procName, _ := evt.GetField("proc.name")
+		pid, _ := evt.GetField("proc.pid")
+		return &Alert{
+			Rule:     m.rule.Name,
+			Severity: m.rule.Severity,
+			Message:  m.rule.Message,
+			ProcName: procName,
+			Pid:      pid,
+			Target:   strings.Join(p.targets, " => "),
+			Event:    evt,
+			Actions:  m.rule.Actions,
+		}
+	}
+	return nil
+}
+
+// start opens a partial match, or restarts one still waiting for step two.
+func (m *sequenceMatcher) start(key string, now int64, target string) {
+	if el := m.partials[key]; el != nil {
+		if el.Value.(*partial).next > 1 {
+			return
+		}
+		m.remove(el)
+	}
+
+	maxState := m.rule.Sequence.MaxState
+	if maxState <= 0 {
+		maxState = DefaultSequenceMaxState
+	}
+	if m.order.Len() >= maxState {
+		m.remove(m.order.Front())
+	}
+
+	p := &partial{key: key, next: 1, start: now, targets: []string{target}}
+	m.partials[key] = m.order.PushBack(p)
+}
+
+func (m *sequenceMatcher) expire(before int64) {
+	for el := m.order.Front(); el != nil && el.Value.(*partial).start < before; el = m.order.Front() {
+		m.remove(el)
+	}
+}
+
+func (m *sequenceMatcher) remove(el *list.Element) {
+	delete(m.partials, el.Value.(*partial).key)
+	m.order.Remove(el)
+}
+
+func joinKey(evt events.EventGetter, fields []string) (string, bool) {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		v, ok := evt.GetField(f)
+		if !ok {
+			return "", false
+		}
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+// eventTime returns evt.ts, the one clock of sequences, thresholds and
+// suppression. Events without it are not matched against stateful rules.
+func eventTime(evt events.EventGetter) (int64, bool) {
+	if evt == nil {
+		return 0, false
+	}
+	v, ok := evt.GetField("evt.ts")
+	if !ok {
+		return 0, false
+	}
+	ts, ok := v.(int64)
+	return ts, ok
+}
+
+// sequences holds the matchers of all sequence rules. State is dropped when
+// the rules are replaced.
+type sequences struct {
+	mu       sync.Mutex
+	matchers []*sequenceMatcher
+}
+
+func newSequences(rules []Rule) *sequences {
+	s := &sequences{}
+	for _, rule := range rules {
+		if rule.Sequence != nil {
+			s.matchers = append(s.matchers, newSequenceMatcher(rule))
+		}
+	}
+	return s
+}
+
+func (s *sequences) process(evt events.EventGetter) []Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []Alert
+	for _, m := range s.matchers {
+		if alert := m.process(evt); alert != nil {
+			res = append(res, *alert)
+		}
+	}
+	return res
+}
@@ -0,0 +1,158 @@
+package analyzer
+
+import (
+	"diploma/internal/events"
+	"testing"
+	"time"
+)
+
+const sec = int64(time.Second)
+
+func common(typ events.EventType, ts int64, pid uint32) events.CommonEvent {
+	c := events.CommonEvent{
+		Header:   events.EventHeader{Type: typ, TimestampNs: uint64(ts)},
+		CgroupId: 77,
+		Pid:      pid,
+		Ppid:     1,
+	}
+	copy(c.Comm[:], "sh")
+	return c
+}
+
+func openat(ts int64, pid uint32, path string) *events.OpenatEvent {
+	return &events.OpenatEvent{Common: common(events.EventOpenat, ts, pid), Ret: 3, Filename: path, Path: path}
+}
+
+func execve(ts int64, pid uint32, path string) *events.ExecveEvent {
+	return &events.ExecveEvent{Common: common(events.EventExecve, ts, pid), Filename: path, Args: []string{path}}
+}
+
+func TestSequence(t *testing.T) {
+	curlThenEtc := func(seq Sequence) Rule {
+		seq.Window = 10 * time.Second
+		seq.Steps = []Step{
+			{EventTypes: []string{"execve"}, Conditions: []Condition{{Field: "proc.exepath", Operator: "=", Value: "/usr/bin/curl"}}},
+			{EventTypes: []string{"openat"}, Conditions: []Condition{{Field: "fd.name", Operator: "startswith", Value: "/etc/"}}},
+		}
+		return Rule{Name: "curl then etc", Sequence: &seq}
+	}
+	writeThenExec := Rule{Name: "write then exec", Sequence: &Sequence{
+		Window: 10 * time.Second,
+		Join:   []string{"path"},
+		Steps: []Step{
+			{EventTypes: []string{"openat"}, Conditions: []Condition{{Field: "fd.name", Operator: "startswith", Value: "/tmp/"}}},
+			{EventTypes: []string{"execve"}, Conditions: []Condition{{Field: "proc.exepath", Operator: "startswith", Value: "/tmp/"}}},
+		},
+	}}
+
+	tests := []struct {
+		name   string
+		rule   Rule
+		events []events.EventGetter
+		want   []int // indexes of the events completing a match
+	}{
+		{
+			name:   "joined by pid",
+			rule:   curlThenEtc(Sequence{Join: []string{"pid"}}),
+			events: []events.EventGetter{execve(0, 1, "/usr/bin/curl"), openat(sec, 1, "/etc/passwd")},
+			want:   []int{1},
+		},
+		{
+			name:   "other pid",
+			rule:   curlThenEtc(Sequence{Join: []string{"pid"}}),
+			events: []events.EventGetter{execve(0, 1, "/usr/bin/curl"), openat(sec, 2, "/etc/passwd")},
+		},
+		{
+			name:   "joined by cgroup",
+			rule:   curlThenEtc(Sequence{Join: []string{"cgroup"}}),
+			events: []events.EventGetter{execve(0, 1, "/usr/bin/curl"), openat(sec, 2, "/etc/passwd")},
+			want:   []int{1},
+		},
+		{
+			name:   "steps out of order",
+			rule:   curlThenEtc(Sequence{Join: []string{"pid"}}),
+			events: []events.EventGetter{openat(0, 1, "/etc/passwd"), execve(sec, 1, "/usr/bin/curl")},
+		},
+		{
+			name:   "window expired",
+			rule:   curlThenEtc(Sequence{Join: []string{"pid"}}),
+			events: []events.EventGetter{execve(0, 1, "/usr/bin/curl"), openat(11*sec, 1, "/etc/passwd")},
+		},
+		{
+			name: "first step restarts the window",
+			rule: curlThenEtc(Sequence{Join: []string{"pid"}}),
+			events: []events.EventGetter{
+				execve(0, 1, "/usr/bin/curl"),
+				execve(5*sec, 1, "/usr/bin/curl"),
+				openat(12*sec, 1, "/etc/passwd"),
+			},
+			want: []int{2},
+		},
+		{
+			name: "each match fires once",
+			rule: curlThenEtc(Sequence{Join: []string{"pid"}}),
+			events: []events.EventGetter{
+				execve(0, 1, "/usr/bin/curl"),
+				openat(sec, 1, "/etc/passwd"),
+				openat(2*sec, 1, "/etc/shadow"),
+			},
+			want: []int{1},
+		},
+		{
+			name: "max_state drops the oldest partial match",
+			rule: curlThenEtc(Sequence{Join: []string{"pid"}, MaxState: 1}),
+			events: []events.EventGetter{
+				execve(0, 1, "/usr/bin/curl"),
+				execve(sec, 2, "/usr/bin/curl"),
+				openat(2*sec, 1, "/etc/passwd"),
+				openat(3*sec, 2, "/etc/passwd"),
+			},
+			want: []int{3},
+		},
+		{
+			name:   "joined by path across event types",
+			rule:   writeThenExec,
+			events: []events.EventGetter{openat(0, 1, "/tmp/x"), execve(sec, 2, "/tmp/y"), execve(2*sec, 3, "/tmp/x")},
+			want:   []int{2},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.rule.Sequence.validate(); err != nil {
+				t.Fatal(err)
+			}
+			m := newSequenceMatcher(tc.rule)
+			var got []int
+			for i, evt := range tc.events {
+				if alert := m.process(evt); alert != nil {
+					got = append(got, i)
+				}
+			}
+			if !equalInts(got, tc.want) {
+				t.Errorf("matches at %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEventTime(t *testing.T) {
+	if ts, ok := eventTime(openat(42, 1, "/x")); !ok || ts != 42 {
+		t.Errorf("eventTime = %d, %v; want 42", ts, ok)
+	}
+	if _, ok := eventTime(nil); ok {
+		t.Error("eventTime of no event: ok")
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+package analyzer
+
+import (
+	"diploma/internal/metrics"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const DefaultSuppressionWindow = time.Minute
+
+// Suppression limits repeated alerts of a rule: within Window at most
+// MaxAlerts alerts with the same Key field values are delivered. The number
+// of suppressed alerts is reported in a summary alert once the window ends.
+// Windows are measured in event time, so a replayed capture is suppressed
+// as the live events were.
+// Only notifications are suppressed; the rule's actions run for every alert.
+type Suppression struct {
+	// Key lists the fields identifying duplicates; empty means the whole rule.
+	Key       []string      `yaml:"key"`
+	Window    time.Duration `yaml:"window"`
+	MaxAlerts int           `yaml:"max_alerts"`
+}
+
+func (s *Suppression) validate() error {
+	if s.Window < 0 {
+		return fmt.Errorf("suppression window must not be negative")
+	}
+	if s.MaxAlerts < 0 {
+		return fmt.Errorf("suppression max_alerts must not be negative")
+	}
+	return nil
+}
+
+func (s *Suppression) window() time.Duration {
+	if s.Window == 0 {
+		return DefaultSuppressionWindow
+	}
+	return s.Window
+}
+
+func (s *Suppression) maxAlerts() int {
+	if s.MaxAlerts == 0 {
+		return 1
+	}
+	return s.MaxAlerts
+}
+
+type suppressState struct {
+	cfg        *Suppression
+	key        string
+	start      int64 // evt.ts of the first alert
+	sent       int
+	suppressed int
+	// last is the most recent suppressed alert, the base of the summary.
+	last Alert
+}
+
+func (s *suppressState) summary() Alert {
+	alert := s.last
+	alert.Message = fmt.Sprintf("%d similar alerts suppressed in %s (%s)", s.suppressed, s.cfg.window(), alert.Message)
+	if len(s.cfg.Key) > 0 {
+		alert.Target = fmt.Sprintf("%s=%s | last %s", strings.Join(s.cfg.Key, ","), strings.ReplaceAll(s.key, "\x00", ","), alert.Target)
+	} else {
+		alert.Target = "last " + alert.Target
+	}
+	// Responders saw every suppressed alert already.
+	alert.Actions = nil
+	return alert
+}
+
+// suppressor tracks the delivery windows of rules with a suppression policy.
+type suppressor struct {
+	mu     sync.Mutex
+	rules  map[string]*Suppression
+	states map[string]*suppressState
+}
+
+func newSuppressor(rules []Rule) *suppressor {
+	s := &suppressor{
+		rules:  make(map[string]*Suppression),
+		states: make(map[string]*suppressState),
+	}
+	for _, rule := range rules {
+		if rule.Suppress != nil {
+			s.rules[rule.Name] = rule.Suppress
+		}
+	}
+	return s
+}
+
+// allow reports whether the alert is delivered; now is the time of its event.
+// An alert opening a new window for its key may flush a summary of the
+// previous one.
+func (s *suppressor) allow(alert Alert, now int64) (bool, []Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg := s.rules[alert.Rule]
+	if cfg == nil {
+		return true, nil
+	}
+
+	var key string
+	if alert.Event != nil {
+		// Alerts lacking a key field share one window.
+		key, _ = joinKey(alert.Event, cfg.Key)
+	}
+	id := alert.Rule + "\x00" + key
+
+	var summaries []Alert
+	st := s.states[id]
+	if st != nil && now-st.start >= int64(cfg.window()) {
+		if st.suppressed > 0 {
+			summaries = append(summaries, st.summary())
+		}
+		st = nil
+	}
+	if st == nil {
+		st = &suppressState{cfg: cfg, key: key, start: now}
+		s.states[id] = st
+	}
+
+	if st.sent < cfg.maxAlerts() {
+		st.sent++
+		return true, summaries
+	}
+	st.suppressed++
+	st.last = alert
+	metrics.AlertsSuppressed.Inc(alert.Rule)
+	return false, summaries
+}
+
+// flush drops windows that ended before now, or all of them if all is set,
+// and returns summaries of those that suppressed alerts. now is on the clock
+// of evt.ts.
+func (s *suppressor) flush(now int64, all bool) []Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var summaries []Alert
+	for id, st := range s.states {
+		if !all && now-st.start < int64(st.cfg.window()) {
+			continue
+		}
+		if st.suppressed > 0 {
+			summaries = append(summaries, st.summary())
+		}
+		delete(s.states, id)
+	}
+	return summaries
+}
+
+// monotonicNow reads the clock of evt.ts, which is CLOCK_MONOTONIC.
+func monotonicNow() int64 {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0
+	}
+	return ts.Nano()
+}
@@ -0,0 +1,136 @@
+package analyzer
+
+import (
+	"diploma/internal/events"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSuppression(t *testing.T) {
+	alertOf := func(evt events.EventGetter) Alert {
+		return Alert{Rule: "noisy", Message: "opened", Target: alertTarget(evt), Event: evt}
+	}
+
+	tests := []struct {
+		name   string
+		cfg    Suppression
+		events []events.EventGetter
+		// delivered marks the events whose alerts are delivered; summaries
+		// counts the summaries delivered along with them.
+		delivered []bool
+		summaries int
+	}{
+		{
+			name:      "one alert per window",
+			cfg:       Suppression{Window: 10 * time.Second},
+			events:    []events.EventGetter{openat(0, 1, "/a"), openat(sec, 1, "/a"), openat(2*sec, 1, "/a")},
+			delivered: []bool{true, false, false},
+		},
+		{
+			name:      "max_alerts",
+			cfg:       Suppression{Window: 10 * time.Second, MaxAlerts: 2},
+			events:    []events.EventGetter{openat(0, 1, "/a"), openat(sec, 1, "/a"), openat(2*sec, 1, "/a")},
+			delivered: []bool{true, true, false},
+		},
+		{
+			name:      "new window after the old one ends",
+			cfg:       Suppression{Window: 10 * time.Second},
+			events:    []events.EventGetter{openat(0, 1, "/a"), openat(sec, 1, "/a"), openat(10*sec, 1, "/a")},
+			delivered: []bool{true, false, true},
+			summaries: 1,
+		},
+		{
+			name:      "keys suppressed apart",
+			cfg:       Suppression{Window: 10 * time.Second, Key: []string{"fd.name"}},
+			events:    []events.EventGetter{openat(0, 1, "/a"), openat(sec, 1, "/b"), openat(2*sec, 1, "/a")},
+			delivered: []bool{true, true, false},
+		},
+		{
+			name:      "window in event time",
+			cfg:       Suppression{Window: time.Minute},
+			events:    []events.EventGetter{openat(0, 1, "/a"), openat(61*sec, 1, "/a")},
+			delivered: []bool{true, true},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			s := newSuppressor([]Rule{{Name: "noisy", Suppress: &tc.cfg}})
+			var summaries int
+			for i, evt := range tc.events {
+				now, _ := eventTime(evt)
+				ok, sums := s.allow(alertOf(evt), now)
+				summaries += len(sums)
+				if ok != tc.delivered[i] {
+					t.Errorf("event %d delivered = %v, want %v", i, ok, tc.delivered[i])
+				}
+			}
+			if summaries != tc.summaries {
+				t.Errorf("%d summaries, want %d", summaries, tc.summaries)
+			}
+		})
+	}
+}
+
+func TestSuppressionFlush(t *testing.T) {
+	cfg := &Suppression{Window: 10 * time.Second, Key: []string{"fd.name"}}
+	s := newSuppressor([]Rule{{Name: "noisy", Suppress: cfg}})
+	for _, evt := range []*events.OpenatEvent{openat(0, 1, "/a"), openat(sec, 1, "/a"), openat(2*sec, 1, "/a"), openat(5*sec, 1, "/b")} {
+		s.allow(Alert{Rule: "noisy", Message: "opened", Event: evt, Actions: []string{"kill"}}, int64(evt.Common.Header.TimestampNs))
+	}
+
+	if sums := s.flush(9*sec, false); len(sums) != 0 {
+		t.Fatalf("flush inside the window: %d summaries", len(sums))
+	}
+	sums := s.flush(12*sec, false)
+	if len(sums) != 1 {
+		t.Fatalf("flush after the window: %d summaries, want 1", len(sums))
+	}
+	if !strings.HasPrefix(sums[0].Message, "2 similar alerts suppressed") || sums[0].Actions != nil {
+		t.Errorf("summary = %+v", sums[0])
+	}
+	// The window of /b suppressed nothing and ends without a summary.
+	if sums := s.flush(0, true); len(sums) != 0 {
+		t.Errorf("final flush: %d summaries, want 0", len(sums))
+	}
+}
+
+func TestEmitRespondsToSuppressedAlerts(t *testing.T) {
+	rule := Rule{
+		Name:       "noisy",
+		EventTypes: []string{"openat"},
+		Conditions: []Condition{{Field: "fd.name", Operator: "=", Value: "/a"}},
+		Suppress:   &Suppression{Window: 10 * time.Second},
+	}
+	a := New(RulesConfig{Rules: []Rule{rule}})
+	a.Offline = true
+	sink, responder := &countSink{}, &countSink{}
+	a.Sinks = []Sink{sink}
+	a.Responders = []Sink{responder}
+
+	for _, evt := range []*events.OpenatEvent{openat(0, 1, "/a"), openat(sec, 1, "/a"), openat(2*sec, 1, "/a")} {
+		a.Handle(evt)
+	}
+	a.FlushSuppressed()
+	// One alert and the summary of the other two.
+	if sink.n != 2 || responder.n != 3 {
+		t.Errorf("sink got %d alerts, responder %d; want 2 and 3", sink.n, responder.n)
+	}
+}
+
+type countSink struct {
+	n int
+}
+
+func (s *countSink) Name() string {
+	return "test"
+}
+
+func (s *countSink) Send(Alert) error {
+	s.n++
+	return nil
+}
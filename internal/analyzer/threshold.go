@@ -0,0 +1,185 @@
+package analyzer
+
+import (
+	"container/list"
+	"diploma/internal/events"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultThresholdMaxGroups = 10000
+
+// Threshold turns a rule into a rate rule: it fires when more than Count
+// matching events (or distinct values of Distinct) are seen for one group
+// within a sliding Window. The group's state is reset after it fires. Events
+// are counted in the order the analyzer checks them: with exe_info enabled,
+// an execve of a file not hashed before is checked once its metadata is
+// read, possibly after later events of the same process.
+type Threshold struct {
+	Window time.Duration `yaml:"window"`
+	// GroupBy lists the fields identifying a group; empty means one group.
+	GroupBy []string `yaml:"group_by"`
+	Count   int      `yaml:"count"`
+	// Distinct counts distinct values of this field instead of events.
+	Distinct string `yaml:"distinct"`
+	// MaxGroups bounds the tracked groups; the least recently updated group
+	// is dropped when it is reached.
+	MaxGroups int `yaml:"max_groups"`
+}
+
+func (t *Threshold) validate() error {
+	if t.Window <= 0 {
+		return fmt.Errorf("threshold needs a positive window")
+	}
+	if t.Count <= 0 {
+		return fmt.Errorf("threshold needs a positive count")
+	}
+	return nil
+}
+
+// thresholdTracker keeps the groups of one rule, least recently updated
+// first.
+type thresholdTracker struct {
+	cfg    *Threshold
+	groups map[string]*list.Element
+	order  *list.List
+}
+
+type thresholdGroup struct {
+	key  string
+	last int64
+	// times holds event times for count rules, oldest first.
+	times []int64
+	// values maps distinct values to the time they were last seen.
+	values map[string]int64
+}
+
+func newThresholdTracker(cfg *Threshold) *thresholdTracker {
+	return &thresholdTracker{
+		cfg:    cfg,
+		groups: make(map[string]*list.Element),
+		order:  list.New(),
+	}
+}
+
+// observe records a matching event and returns a summary when the group
+// crosses the threshold.
+func (t *thresholdTracker) observe(evt events.EventGetter) (string, bool) {
+	now, ok := eventTime(evt)
+	if !ok {
+		return "", false
+	}
+	since := now - int64(t.cfg.Window)
+	t.expire(since)
+
+	key, ok := joinKey(evt, t.cfg.GroupBy)
+	if !ok {
+		return "", false
+	}
+	g := t.group(key, now)
+
+	var n int
+	if t.cfg.Distinct == "" {
+		g.times = append(g.times, now)
+		for len(g.times) > 0 && g.times[0] < since {
+			g.times = g.times[1:]
+		}
+		n = len(g.times)
+	} else {
+		v, ok := evt.GetField(t.cfg.Distinct)
+		if !ok {
+			return "", false
+		}
+		g.values[fmt.Sprint(v)] = now
+		// Expired values only matter once the threshold seems crossed, which
+		// also bounds the map to Count+1 live entries.
+		if len(g.values) > t.cfg.Count {
+			for val, ts := range g.values {
+				if ts < since {
+					delete(g.values, val)
+				}
+			}
+		}
+		n = len(g.values)
+	}
+	if n <= t.cfg.Count {
+		return "", false
+	}
+
+	t.remove(t.groups[key])
+	what := "events"
+	if t.cfg.Distinct != "" {
+		what = "distinct " + t.cfg.Distinct
+	}
+	summary := fmt.Sprintf("%d %s in %s", n, what, t.cfg.Window)
+	if len(t.cfg.GroupBy) > 0 {
+		summary += fmt.Sprintf(" for %s=%s", strings.Join(t.cfg.GroupBy, ","), strings.ReplaceAll(key, "\x00", ","))
+	}
+	return summary, true
+}
+
+func (t *thresholdTracker) group(key string, now int64) *thresholdGroup {
+	if el := t.groups[key]; el != nil {
+		g := el.Value.(*thresholdGroup)
+		g.last = now
+		t.order.MoveToBack(el)
+		return g
+	}
+
+	maxGroups := t.cfg.MaxGroups
+	if maxGroups <= 0 {
+		maxGroups = DefaultThresholdMaxGroups
+	}
+	if t.order.Len() >= maxGroups {
+		t.remove(t.order.Front())
+	}
+
+	g := &thresholdGroup{key: key, last: now}
+	if t.cfg.Distinct != "" {
+		g.values = make(map[string]int64)
+	}
+	t.groups[key] = t.order.PushBack(g)
+	return g
+}
+
+// expire drops groups without events inside the window.
+func (t *thresholdTracker) expire(before int64) {
+	for el := t.order.Front(); el != nil && el.Value.(*thresholdGroup).last < before; el = t.order.Front() {
+		t.remove(el)
+	}
+}
+
+func (t *thresholdTracker) remove(el *list.Element) {
+	delete(t.groups, el.Value.(*thresholdGroup).key)
+	t.order.Remove(el)
+}
+
+// thresholds holds the trackers of all threshold rules by rule name. State
+// is dropped when the rules are replaced.
+type thresholds struct {
+	mu       sync.Mutex
+	trackers map[string]*thresholdTracker
+}
+
+func newThresholds(rules []Rule) *thresholds {
+	t := &thresholds{trackers: make(map[string]*thresholdTracker)}
+	for _, rule := range rules {
+		if rule.Threshold != nil {
+			t.trackers[rule.Name] = newThresholdTracker(rule.Threshold)
+		}
+	}
+	return t
+}
+
+func (t *thresholds) observe(rule *Rule, evt events.EventGetter) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracker := t.trackers[rule.Name]
+	if tracker == nil {
+		return "", false
+	}
+	return tracker.observe(evt)
+}
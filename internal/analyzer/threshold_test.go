@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"diploma/internal/events"
+	"testing"
+	"time"
+)
+
+func TestThreshold(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Threshold
+		events []events.EventGetter
+		want   []string // summaries of the events crossing the threshold, "" if none
+	}{
+		{
+			name:   "count crossed",
+			cfg:    Threshold{Window: 10 * time.Second, Count: 2},
+			events: []events.EventGetter{openat(0, 1, "/a"), openat(sec, 1, "/a"), openat(2*sec, 1, "/a")},
+			want:   []string{"", "", "3 events in 10s"},
+		},
+		{
+			name:   "old events leave the window",
+			cfg:    Threshold{Window: 10 * time.Second, Count: 2},
+			events: []events.EventGetter{openat(0, 1, "/a"), openat(sec, 1, "/a"), openat(11*sec, 1, "/a"), openat(12*sec, 1, "/a"), openat(13*sec, 1, "/a")},
+			want:   []string{"", "", "", "", "3 events in 10s"},
+		},
+		{
+			name:   "state resets after firing",
+			cfg:    Threshold{Window: 10 * time.Second, Count: 1},
+			events: []events.EventGetter{openat(0, 1, "/a"), openat(sec, 1, "/a"), openat(2*sec, 1, "/a"), openat(3*sec, 1, "/a")},
+			want:   []string{"", "2 events in 10s", "", "2 events in 10s"},
+		},
+		{
+			name:   "groups counted apart",
+			cfg:    Threshold{Window: 10 * time.Second, Count: 1, GroupBy: []string{"proc.pid"}},
+			events: []events.EventGetter{openat(0, 1, "/a"), openat(sec, 2, "/a"), openat(2*sec, 1, "/a")},
+			want:   []string{"", "", "2 events in 10s for proc.pid=1"},
+		},
+		{
+			name:   "distinct values",
+			cfg:    Threshold{Window: 10 * time.Second, Count: 2, Distinct: "fd.name"},
+			events: []events.EventGetter{openat(0, 1, "/a"), openat(sec, 1, "/a"), openat(2*sec, 1, "/b"), openat(3*sec, 1, "/c")},
+			want:   []string{"", "", "", "3 distinct fd.name in 10s"},
+		},
+		{
+			name:   "expired distinct values",
+			cfg:    Threshold{Window: 10 * time.Second, Count: 2, Distinct: "fd.name"},
+			events: []events.EventGetter{openat(0, 1, "/a"), openat(5*sec, 1, "/b"), openat(12*sec, 1, "/c"), openat(13*sec, 1, "/d")},
+			want:   []string{"", "", "", "3 distinct fd.name in 10s"},
+		},
+		{
+			name: "max_groups drops the least recently updated group",
+			cfg:  Threshold{Window: 10 * time.Second, Count: 1, GroupBy: []string{"proc.pid"}, MaxGroups: 2},
+			events: []events.EventGetter{
+				openat(0, 1, "/a"),
+				openat(sec, 2, "/a"),
+				openat(2*sec, 3, "/a"),
+				openat(3*sec, 1, "/a"),
+				openat(4*sec, 3, "/a"),
+			},
+			want: []string{"", "", "", "", "2 events in 10s for proc.pid=3"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			tr := newThresholdTracker(&tc.cfg)
+			for i, evt := range tc.events {
+				summary, fired := tr.observe(evt)
+				if fired != (tc.want[i] != "") || summary != tc.want[i] {
+					t.Errorf("event %d: %q, %v; want %q", i, summary, fired, tc.want[i])
+				}
+			}
+		})
+	}
+}
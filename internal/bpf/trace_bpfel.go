@@ -22,7 +22,7 @@ type TraceChmodArgsT struct {
 	_        structs.HostLayout
 	Dfd      int32
 	Mode     uint32
-	Filename [128]int8
+	Filename [4096]int8
 }
 
 type TraceConnectArgsT struct {
@@ -33,11 +33,54 @@ type TraceConnectArgsT struct {
 	_    [2]byte
 }
 
+type TraceDenyAddrKey struct {
+	_    structs.HostLayout
+	Ip   uint32
+	Port uint16
+	_    [2]byte
+}
+
+type TraceDenyPathValue struct {
+	_         structs.HostLayout
+	PrefixLen uint32
+	Count     uint32
+	ExactBits uint32
+	FlagMasks [4]uint32
+	RuleIds   [4]uint32
+}
+
+type TraceDropStats struct {
+	_          structs.HostLayout
+	Ringbuf    uint64
+	TmpStorage uint64
+}
+
+type TraceEnforceConfig struct {
+	_                structs.HostLayout
+	Mode             uint32
+	PtraceAttachRule uint32
+	PtraceSeizeRule  uint32
+	OpenRule         uint32
+	ExecRule         uint32
+}
+
 type TraceExecveArgsT struct {
 	_        structs.HostLayout
-	Filename [128]int8
-	Argv     [24][64]int8
-	Envp     [24][64]int8
+	Argv     uint64
+	Envp     uint64
+	Filename [4096]int8
+}
+
+type TraceExecveConfig struct {
+	_          structs.HostLayout
+	MaxArgsLen uint32
+	MaxEnvLen  uint32
+}
+
+type TraceExeKey struct {
+	_   structs.HostLayout
+	Ino uint64
+	Dev uint64
 }
 
 type TraceMemfdArgsT struct {
@@ -50,7 +93,26 @@ type TraceOpenatArgsT struct {
 	_        structs.HostLayout
 	Dfd      int32
 	Flags    int32
-	Filename [128]int8
+	Filename [4096]int8
+}
+
+type TraceOpenatFilterConfig struct {
+	_            structs.HostLayout
+	Enabled      uint32
+	AnyPathCount uint32
+	AnyPathMasks [8]uint32
+}
+
+type TracePathFilterKey struct {
+	_         structs.HostLayout
+	Prefixlen uint32
+	Path      [64]int8
+}
+
+type TracePathFilterValue struct {
+	_         structs.HostLayout
+	Count     uint32
+	FlagMasks [8]uint32
 }
 
 type TracePtraceArgsT struct {
@@ -103,40 +165,89 @@ type TraceSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type TraceProgramSpecs struct {
-	TraceEnterAccept4     *ebpf.ProgramSpec `ebpf:"trace_enter_accept4"`
-	TraceEnterConnect     *ebpf.ProgramSpec `ebpf:"trace_enter_connect"`
-	TraceEnterExecve      *ebpf.ProgramSpec `ebpf:"trace_enter_execve"`
-	TraceEnterFchmodat    *ebpf.ProgramSpec `ebpf:"trace_enter_fchmodat"`
-	TraceEnterMemfdCreate *ebpf.ProgramSpec `ebpf:"trace_enter_memfd_create"`
-	TraceEnterOpenat      *ebpf.ProgramSpec `ebpf:"trace_enter_openat"`
-	TraceEnterPtrace      *ebpf.ProgramSpec `ebpf:"trace_enter_ptrace"`
-	TraceExitAccept4      *ebpf.ProgramSpec `ebpf:"trace_exit_accept4"`
-	TraceExitConnect      *ebpf.ProgramSpec `ebpf:"trace_exit_connect"`
-	TraceExitExecve       *ebpf.ProgramSpec `ebpf:"trace_exit_execve"`
-	TraceExitFchmodat     *ebpf.ProgramSpec `ebpf:"trace_exit_fchmodat"`
-	TraceExitMemfdCreate  *ebpf.ProgramSpec `ebpf:"trace_exit_memfd_create"`
-	TraceExitOpenat       *ebpf.ProgramSpec `ebpf:"trace_exit_openat"`
-	TraceExitPtrace       *ebpf.ProgramSpec `ebpf:"trace_exit_ptrace"`
+	CgroupDropEgress       *ebpf.ProgramSpec `ebpf:"cgroup_drop_egress"`
+	CgroupDropIngress      *ebpf.ProgramSpec `ebpf:"cgroup_drop_ingress"`
+	FexitAccept4           *ebpf.ProgramSpec `ebpf:"fexit_accept4"`
+	FexitConnect           *ebpf.ProgramSpec `ebpf:"fexit_connect"`
+	FexitFchmodat          *ebpf.ProgramSpec `ebpf:"fexit_fchmodat"`
+	FexitFchmodat2         *ebpf.ProgramSpec `ebpf:"fexit_fchmodat2"`
+	FexitMemfdCreate       *ebpf.ProgramSpec `ebpf:"fexit_memfd_create"`
+	FexitOpenat            *ebpf.ProgramSpec `ebpf:"fexit_openat"`
+	FexitPtrace            *ebpf.ProgramSpec `ebpf:"fexit_ptrace"`
+	KprobeEnterAccept4     *ebpf.ProgramSpec `ebpf:"kprobe_enter_accept4"`
+	KprobeEnterConnect     *ebpf.ProgramSpec `ebpf:"kprobe_enter_connect"`
+	KprobeEnterExecve      *ebpf.ProgramSpec `ebpf:"kprobe_enter_execve"`
+	KprobeEnterFchmodat    *ebpf.ProgramSpec `ebpf:"kprobe_enter_fchmodat"`
+	KprobeEnterFchmodat2   *ebpf.ProgramSpec `ebpf:"kprobe_enter_fchmodat2"`
+	KprobeEnterMemfdCreate *ebpf.ProgramSpec `ebpf:"kprobe_enter_memfd_create"`
+	KprobeEnterOpenat      *ebpf.ProgramSpec `ebpf:"kprobe_enter_openat"`
+	KprobeEnterPtrace      *ebpf.ProgramSpec `ebpf:"kprobe_enter_ptrace"`
+	KprobeExitAccept4      *ebpf.ProgramSpec `ebpf:"kprobe_exit_accept4"`
+	KprobeExitConnect      *ebpf.ProgramSpec `ebpf:"kprobe_exit_connect"`
+	KprobeExitExecve       *ebpf.ProgramSpec `ebpf:"kprobe_exit_execve"`
+	KprobeExitFchmodat     *ebpf.ProgramSpec `ebpf:"kprobe_exit_fchmodat"`
+	KprobeExitFchmodat2    *ebpf.ProgramSpec `ebpf:"kprobe_exit_fchmodat2"`
+	KprobeExitMemfdCreate  *ebpf.ProgramSpec `ebpf:"kprobe_exit_memfd_create"`
+	KprobeExitOpenat       *ebpf.ProgramSpec `ebpf:"kprobe_exit_openat"`
+	KprobeExitPtrace       *ebpf.ProgramSpec `ebpf:"kprobe_exit_ptrace"`
+	LsmBprmCheckSecurity   *ebpf.ProgramSpec `ebpf:"lsm_bprm_check_security"`
+	LsmEnterPtrace         *ebpf.ProgramSpec `ebpf:"lsm_enter_ptrace"`
+	LsmExitPtrace          *ebpf.ProgramSpec `ebpf:"lsm_exit_ptrace"`
+	LsmFileOpen            *ebpf.ProgramSpec `ebpf:"lsm_file_open"`
+	LsmPtraceAccessCheck   *ebpf.ProgramSpec `ebpf:"lsm_ptrace_access_check"`
+	LsmSocketConnect       *ebpf.ProgramSpec `ebpf:"lsm_socket_connect"`
+	TraceEnterAccept4      *ebpf.ProgramSpec `ebpf:"trace_enter_accept4"`
+	TraceEnterConnect      *ebpf.ProgramSpec `ebpf:"trace_enter_connect"`
+	TraceEnterExecve       *ebpf.ProgramSpec `ebpf:"trace_enter_execve"`
+	TraceEnterFchmodat     *ebpf.ProgramSpec `ebpf:"trace_enter_fchmodat"`
+	TraceEnterFchmodat2    *ebpf.ProgramSpec `ebpf:"trace_enter_fchmodat2"`
+	TraceEnterMemfdCreate  *ebpf.ProgramSpec `ebpf:"trace_enter_memfd_create"`
+	TraceEnterOpenat       *ebpf.ProgramSpec `ebpf:"trace_enter_openat"`
+	TraceEnterPtrace       *ebpf.ProgramSpec `ebpf:"trace_enter_ptrace"`
+	TraceExitAccept4       *ebpf.ProgramSpec `ebpf:"trace_exit_accept4"`
+	TraceExitConnect       *ebpf.ProgramSpec `ebpf:"trace_exit_connect"`
+	TraceExitExecve        *ebpf.ProgramSpec `ebpf:"trace_exit_execve"`
+	TraceExitFchmodat      *ebpf.ProgramSpec `ebpf:"trace_exit_fchmodat"`
+	TraceExitFchmodat2     *ebpf.ProgramSpec `ebpf:"trace_exit_fchmodat2"`
+	TraceExitMemfdCreate   *ebpf.ProgramSpec `ebpf:"trace_exit_memfd_create"`
+	TraceExitOpenat        *ebpf.ProgramSpec `ebpf:"trace_exit_openat"`
+	TraceExitPtrace        *ebpf.ProgramSpec `ebpf:"trace_exit_ptrace"`
 }
 
 // TraceMapSpecs contains maps before they are loaded into the kernel.
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type TraceMapSpecs struct {
-	AcceptEvents      *ebpf.MapSpec `ebpf:"accept_events"`
 	AcceptTmpStorage  *ebpf.MapSpec `ebpf:"accept_tmp_storage"`
-	ChmodEvents       *ebpf.MapSpec `ebpf:"chmod_events"`
+	ChmodArgsHeap     *ebpf.MapSpec `ebpf:"chmod_args_heap"`
+	ChmodEventHeap    *ebpf.MapSpec `ebpf:"chmod_event_heap"`
 	ChmodTmpStorage   *ebpf.MapSpec `ebpf:"chmod_tmp_storage"`
-	ConnectEvents     *ebpf.MapSpec `ebpf:"connect_events"`
 	ConnectTmpStorage *ebpf.MapSpec `ebpf:"connect_tmp_storage"`
-	ExecveEvents      *ebpf.MapSpec `ebpf:"execve_events"`
-	ExecveHeap        *ebpf.MapSpec `ebpf:"execve_heap"`
+	DenyConnect       *ebpf.MapSpec `ebpf:"deny_connect"`
+	DenyEventHeap     *ebpf.MapSpec `ebpf:"deny_event_heap"`
+	DenyExecPaths     *ebpf.MapSpec `ebpf:"deny_exec_paths"`
+	DenyOpenPaths     *ebpf.MapSpec `ebpf:"deny_open_paths"`
+	DenyPathHeap      *ebpf.MapSpec `ebpf:"deny_path_heap"`
+	DropCounters      *ebpf.MapSpec `ebpf:"drop_counters"`
+	EnforceConfig     *ebpf.MapSpec `ebpf:"enforce_config"`
+	Events            *ebpf.MapSpec `ebpf:"events"`
+	ExecveArgsHeap    *ebpf.MapSpec `ebpf:"execve_args_heap"`
+	ExecveConfig      *ebpf.MapSpec `ebpf:"execve_config"`
+	ExecveEventHeap   *ebpf.MapSpec `ebpf:"execve_event_heap"`
 	ExecveTmpStorage  *ebpf.MapSpec `ebpf:"execve_tmp_storage"`
-	MemfdEvents       *ebpf.MapSpec `ebpf:"memfd_events"`
+	IgnoreCgroups     *ebpf.MapSpec `ebpf:"ignore_cgroups"`
+	IgnoreComms       *ebpf.MapSpec `ebpf:"ignore_comms"`
+	IgnoreConfig      *ebpf.MapSpec `ebpf:"ignore_config"`
+	IgnoreExes        *ebpf.MapSpec `ebpf:"ignore_exes"`
+	IgnoreUids        *ebpf.MapSpec `ebpf:"ignore_uids"`
 	MemfdTmpStorage   *ebpf.MapSpec `ebpf:"memfd_tmp_storage"`
-	OpenatEvents      *ebpf.MapSpec `ebpf:"openat_events"`
+	OpenatArgsHeap    *ebpf.MapSpec `ebpf:"openat_args_heap"`
+	OpenatEventHeap   *ebpf.MapSpec `ebpf:"openat_event_heap"`
+	OpenatFilter      *ebpf.MapSpec `ebpf:"openat_filter"`
+	OpenatPathFilter  *ebpf.MapSpec `ebpf:"openat_path_filter"`
 	OpenatTmpStorage  *ebpf.MapSpec `ebpf:"openat_tmp_storage"`
-	PtraceEvents      *ebpf.MapSpec `ebpf:"ptrace_events"`
+	PathHeap          *ebpf.MapSpec `ebpf:"path_heap"`
+	PtraceAttaching   *ebpf.MapSpec `ebpf:"ptrace_attaching"`
 	PtraceTmpStorage  *ebpf.MapSpec `ebpf:"ptrace_tmp_storage"`
 }
 
@@ -166,39 +277,71 @@ func (o *TraceObjects) Close() error {
 //
 // It can be passed to LoadTraceObjects or ebpf.CollectionSpec.LoadAndAssign.
 type TraceMaps struct {
-	AcceptEvents      *ebpf.Map `ebpf:"accept_events"`
 	AcceptTmpStorage  *ebpf.Map `ebpf:"accept_tmp_storage"`
-	ChmodEvents       *ebpf.Map `ebpf:"chmod_events"`
+	ChmodArgsHeap     *ebpf.Map `ebpf:"chmod_args_heap"`
+	ChmodEventHeap    *ebpf.Map `ebpf:"chmod_event_heap"`
 	ChmodTmpStorage   *ebpf.Map `ebpf:"chmod_tmp_storage"`
-	ConnectEvents     *ebpf.Map `ebpf:"connect_events"`
 	ConnectTmpStorage *ebpf.Map `ebpf:"connect_tmp_storage"`
-	ExecveEvents      *ebpf.Map `ebpf:"execve_events"`
-	ExecveHeap        *ebpf.Map `ebpf:"execve_heap"`
+	DenyConnect       *ebpf.Map `ebpf:"deny_connect"`
+	DenyEventHeap     *ebpf.Map `ebpf:"deny_event_heap"`
+	DenyExecPaths     *ebpf.Map `ebpf:"deny_exec_paths"`
+	DenyOpenPaths     *ebpf.Map `ebpf:"deny_open_paths"`
+	DenyPathHeap      *ebpf.Map `ebpf:"deny_path_heap"`
+	DropCounters      *ebpf.Map `ebpf:"drop_counters"`
+	EnforceConfig     *ebpf.Map `ebpf:"enforce_config"`
+	Events            *ebpf.Map `ebpf:"events"`
+	ExecveArgsHeap    *ebpf.Map `ebpf:"execve_args_heap"`
+	ExecveConfig      *ebpf.Map `ebpf:"execve_config"`
+	ExecveEventHeap   *ebpf.Map `ebpf:"execve_event_heap"`
 	ExecveTmpStorage  *ebpf.Map `ebpf:"execve_tmp_storage"`
-	MemfdEvents       *ebpf.Map `ebpf:"memfd_events"`
+	IgnoreCgroups     *ebpf.Map `ebpf:"ignore_cgroups"`
+	IgnoreComms       *ebpf.Map `ebpf:"ignore_comms"`
+	IgnoreConfig      *ebpf.Map `ebpf:"ignore_config"`
+	IgnoreExes        *ebpf.Map `ebpf:"ignore_exes"`
+	IgnoreUids        *ebpf.Map `ebpf:"ignore_uids"`
 	MemfdTmpStorage   *ebpf.Map `ebpf:"memfd_tmp_storage"`
-	OpenatEvents      *ebpf.Map `ebpf:"openat_events"`
+	OpenatArgsHeap    *ebpf.Map `ebpf:"openat_args_heap"`
+	OpenatEventHeap   *ebpf.Map `ebpf:"openat_event_heap"`
+	OpenatFilter      *ebpf.Map `ebpf:"openat_filter"`
+	OpenatPathFilter  *ebpf.Map `ebpf:"openat_path_filter"`
 	OpenatTmpStorage  *ebpf.Map `ebpf:"openat_tmp_storage"`
-	PtraceEvents      *ebpf.Map `ebpf:"ptrace_events"`
+	PathHeap          *ebpf.Map `ebpf:"path_heap"`
+	PtraceAttaching   *ebpf.Map `ebpf:"ptrace_attaching"`
 	PtraceTmpStorage  *ebpf.Map `ebpf:"ptrace_tmp_storage"`
 }
 
 func (m *TraceMaps) Close() error {
 	return _TraceClose(
-		m.AcceptEvents,
 		m.AcceptTmpStorage,
-		m.ChmodEvents,
+		m.ChmodArgsHeap,
+		m.ChmodEventHeap,
 		m.ChmodTmpStorage,
-		m.ConnectEvents,
 		m.ConnectTmpStorage,
-		m.ExecveEvents,
-		m.ExecveHeap,
+		m.DenyConnect,
+		m.DenyEventHeap,
+		m.DenyExecPaths,
+		m.DenyOpenPaths,
+		m.DenyPathHeap,
+		m.DropCounters,
+		m.EnforceConfig,
+		m.Events,
+		m.ExecveArgsHeap,
+		m.ExecveConfig,
+		m.ExecveEventHeap,
 		m.ExecveTmpStorage,
-		m.MemfdEvents,
+		m.IgnoreCgroups,
+		m.IgnoreComms,
+		m.IgnoreConfig,
+		m.IgnoreExes,
+		m.IgnoreUids,
 		m.MemfdTmpStorage,
-		m.OpenatEvents,
+		m.OpenatArgsHeap,
+		m.OpenatEventHeap,
+		m.OpenatFilter,
+		m.OpenatPathFilter,
 		m.OpenatTmpStorage,
-		m.PtraceEvents,
+		m.PathHeap,
+		m.PtraceAttaching,
 		m.PtraceTmpStorage,
 	)
 }
@@ -213,28 +356,93 @@ type TraceVariables struct {
 //
 // It can be passed to LoadTraceObjects or ebpf.CollectionSpec.LoadAndAssign.
 type TracePrograms struct {
-	TraceEnterAccept4     *ebpf.Program `ebpf:"trace_enter_accept4"`
-	TraceEnterConnect     *ebpf.Program `ebpf:"trace_enter_connect"`
-	TraceEnterExecve      *ebpf.Program `ebpf:"trace_enter_execve"`
-	TraceEnterFchmodat    *ebpf.Program `ebpf:"trace_enter_fchmodat"`
-	TraceEnterMemfdCreate *ebpf.Program `ebpf:"trace_enter_memfd_create"`
-	TraceEnterOpenat      *ebpf.Program `ebpf:"trace_enter_openat"`
-	TraceEnterPtrace      *ebpf.Program `ebpf:"trace_enter_ptrace"`
-	TraceExitAccept4      *ebpf.Program `ebpf:"trace_exit_accept4"`
-	TraceExitConnect      *ebpf.Program `ebpf:"trace_exit_connect"`
-	TraceExitExecve       *ebpf.Program `ebpf:"trace_exit_execve"`
-	TraceExitFchmodat     *ebpf.Program `ebpf:"trace_exit_fchmodat"`
-	TraceExitMemfdCreate  *ebpf.Program `ebpf:"trace_exit_memfd_create"`
-	TraceExitOpenat       *ebpf.Program `ebpf:"trace_exit_openat"`
-	TraceExitPtrace       *ebpf.Program `ebpf:"trace_exit_ptrace"`
+	CgroupDropEgress       *ebpf.Program `ebpf:"cgroup_drop_egress"`
+	CgroupDropIngress      *ebpf.Program `ebpf:"cgroup_drop_ingress"`
+	FexitAccept4           *ebpf.Program `ebpf:"fexit_accept4"`
+	FexitConnect           *ebpf.Program `ebpf:"fexit_connect"`
+	FexitFchmodat          *ebpf.Program `ebpf:"fexit_fchmodat"`
+	FexitFchmodat2         *ebpf.Program `ebpf:"fexit_fchmodat2"`
+	FexitMemfdCreate       *ebpf.Program `ebpf:"fexit_memfd_create"`
+	FexitOpenat            *ebpf.Program `ebpf:"fexit_openat"`
+	FexitPtrace            *ebpf.Program `ebpf:"fexit_ptrace"`
+	KprobeEnterAccept4     *ebpf.Program `ebpf:"kprobe_enter_accept4"`
+	KprobeEnterConnect     *ebpf.Program `ebpf:"kprobe_enter_connect"`
+	KprobeEnterExecve      *ebpf.Program `ebpf:"kprobe_enter_execve"`
+	KprobeEnterFchmodat    *ebpf.Program `ebpf:"kprobe_enter_fchmodat"`
+	KprobeEnterFchmodat2   *ebpf.Program `ebpf:"kprobe_enter_fchmodat2"`
+	KprobeEnterMemfdCreate *ebpf.Program `ebpf:"kprobe_enter_memfd_create"`
+	KprobeEnterOpenat      *ebpf.Program `ebpf:"kprobe_enter_openat"`
+	KprobeEnterPtrace      *ebpf.Program `ebpf:"kprobe_enter_ptrace"`
+	KprobeExitAccept4      *ebpf.Program `ebpf:"kprobe_exit_accept4"`
+	KprobeExitConnect      *ebpf.Program `ebpf:"kprobe_exit_connect"`
+	KprobeExitExecve       *ebpf.Program `ebpf:"kprobe_exit_execve"`
+	KprobeExitFchmodat     *ebpf.Program `ebpf:"kprobe_exit_fchmodat"`
+	KprobeExitFchmodat2    *ebpf.Program `ebpf:"kprobe_exit_fchmodat2"`
+	KprobeExitMemfdCreate  *ebpf.Program `ebpf:"kprobe_exit_memfd_create"`
+	KprobeExitOpenat       *ebpf.Program `ebpf:"kprobe_exit_openat"`
+	KprobeExitPtrace       *ebpf.Program `ebpf:"kprobe_exit_ptrace"`
+	LsmBprmCheckSecurity   *ebpf.Program `ebpf:"lsm_bprm_check_security"`
+	LsmEnterPtrace         *ebpf.Program `ebpf:"lsm_enter_ptrace"`
+	LsmExitPtrace          *ebpf.Program `ebpf:"lsm_exit_ptrace"`
+	LsmFileOpen            *ebpf.Program `ebpf:"lsm_file_open"`
+	LsmPtraceAccessCheck   *ebpf.Program `ebpf:"lsm_ptrace_access_check"`
+	LsmSocketConnect       *ebpf.Program `ebpf:"lsm_socket_connect"`
+	TraceEnterAccept4      *ebpf.Program `ebpf:"trace_enter_accept4"`
+	TraceEnterConnect      *ebpf.Program `ebpf:"trace_enter_connect"`
+	TraceEnterExecve       *ebpf.Program `ebpf:"trace_enter_execve"`
+	TraceEnterFchmodat     *ebpf.Program `ebpf:"trace_enter_fchmodat"`
+	TraceEnterFchmodat2    *ebpf.Program `ebpf:"trace_enter_fchmodat2"`
+	TraceEnterMemfdCreate  *ebpf.Program `ebpf:"trace_enter_memfd_create"`
+	TraceEnterOpenat       *ebpf.Program `ebpf:"trace_enter_openat"`
+	TraceEnterPtrace       *ebpf.Program `ebpf:"trace_enter_ptrace"`
+	TraceExitAccept4       *ebpf.Program `ebpf:"trace_exit_accept4"`
+	TraceExitConnect       *ebpf.Program `ebpf:"trace_exit_connect"`
+	TraceExitExecve        *ebpf.Program `ebpf:"trace_exit_execve"`
+	TraceExitFchmodat      *ebpf.Program `ebpf:"trace_exit_fchmodat"`
+	TraceExitFchmodat2     *ebpf.Program `ebpf:"trace_exit_fchmodat2"`
+	TraceExitMemfdCreate   *ebpf.Program `ebpf:"trace_exit_memfd_create"`
+	TraceExitOpenat        *ebpf.Program `ebpf:"trace_exit_openat"`
+	TraceExitPtrace        *ebpf.Program `ebpf:"trace_exit_ptrace"`
 }
 
 func (p *TracePrograms) Close() error {
 	return _TraceClose(
+		p.CgroupDropEgress,
+		p.CgroupDropIngress,
+		p.FexitAccept4,
+		p.FexitConnect,
+		p.FexitFchmodat,
+		p.FexitFchmodat2,
+		p.FexitMemfdCreate,
+		p.FexitOpenat,
+		p.FexitPtrace,
+		p.KprobeEnterAccept4,
+		p.KprobeEnterConnect,
+		p.KprobeEnterExecve,
+		p.KprobeEnterFchmodat,
+		p.KprobeEnterFchmodat2,
+		p.KprobeEnterMemfdCreate,
+		p.KprobeEnterOpenat,
+		p.KprobeEnterPtrace,
+		p.KprobeExitAccept4,
+		p.KprobeExitConnect,
+		p.KprobeExitExecve,
+		p.KprobeExitFchmodat,
+		p.KprobeExitFchmodat2,
+		p.KprobeExitMemfdCreate,
+		p.KprobeExitOpenat,
+		p.KprobeExitPtrace,
+		p.LsmBprmCheckSecurity,
+		p.LsmEnterPtrace,
+		p.LsmExitPtrace,
+		p.LsmFileOpen,
+		p.LsmPtraceAccessCheck,
+		p.LsmSocketConnect,
 		p.TraceEnterAccept4,
 		p.TraceEnterConnect,
 		p.TraceEnterExecve,
 		p.TraceEnterFchmodat,
+		p.TraceEnterFchmodat2,
 		p.TraceEnterMemfdCreate,
 		p.TraceEnterOpenat,
 		p.TraceEnterPtrace,
@@ -242,6 +450,7 @@ func (p *TracePrograms) Close() error {
 		p.TraceExitConnect,
 		p.TraceExitExecve,
 		p.TraceExitFchmodat,
+		p.TraceExitFchmodat2,
 		p.TraceExitMemfdCreate,
 		p.TraceExitOpenat,
 		p.TraceExitPtrace,
@@ -0,0 +1,251 @@
+// Package capture writes events read from the ring buffer to a file and reads
+// them back, so that detections can be replayed without loading BPF.
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"diploma/internal/events"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	FormatBinary = "binary"
+	FormatJSONL  = "jsonl"
+)
+
+// Binary captures start with magic, a version byte and the byte order of the
+// recording host ('L' or 'B'). Each record follows as a uint32 length and the
+// raw ring buffer record, both in that byte order. Since version 2 the rule
+// name of a deny event follows its record, within the length.
+var magic = []byte("SMCAP\x00")
+
+const (
+	version   = 2
+	headerLen = 8
+	// maxRecord guards against reading garbage as a huge length.
+	maxRecord = 1 << 20
+)
+
+type jsonRecord struct {
+	Type  string          `json:"type"`
+	Event json.RawMessage `json:"event"`
+}
+
+func hostOrder() byte {
+	var b [2]byte
+	binary.NativeEndian.PutUint16(b[:], 1)
+	if b[0] == 1 {
+		return 'L'
+	}
+	return 'B'
+}
+
+// Writer appends events to a capture file. It is safe for concurrent use.
+// After the first write error nothing more is written; Close reports it.
+type Writer struct {
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	format string
+	err    error
+}
+
+func Create(path, format string) (*Writer, error) {
+	if format == "" {
+		format = FormatBinary
+	}
+	if format != FormatBinary && format != FormatJSONL {
+		return nil, fmt.Errorf("unknown capture format %q (want binary or jsonl)", format)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{f: f, w: bufio.NewWriterSize(f, 1<<16), format: format}
+	if format == FormatBinary {
+		hdr := append(append([]byte{}, magic...), version, hostOrder())
+		if _, err := w.w.Write(hdr); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Write records a decoded event; raw is the ring buffer record it came from.
+func (w *Writer) Write(evt events.EventGetter, raw []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return nil
+	}
+	w.err = w.write(evt, raw)
+	return w.err
+}
+
+func (w *Writer) write(evt events.EventGetter, raw []byte) error {
+	if w.format == FormatJSONL {
+		data, err := json.Marshal(evt)
+		if err != nil {
+			return err
+		}
+		line, err := json.Marshal(jsonRecord{Type: evt.GetType(), Event: data})
+		if err != nil {
+			return err
+		}
+		_, err = w.w.Write(append(line, '\n'))
+		return err
+	}
+
+	var name string
+	if deny, ok := evt.(*events.DenyEvent); ok {
+		name = deny.RuleName
+	}
+	var n [4]byte
+	binary.NativeEndian.PutUint32(n[:], uint32(len(raw)+len(name)))
+	if _, err := w.w.Write(n[:]); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(raw); err != nil {
+		return err
+	}
+	_, err := w.w.WriteString(name)
+	return err
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+	err := w.w.Flush()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if w.err != nil {
+		err = w.err
+	}
+	// Events still arriving from the pollers are dropped.
+	w.f = nil
+	w.err = os.ErrClosed
+	return err
+}
+
+// Reader reads events from a capture in either format.
+type Reader struct {
+	f      *os.File
+	r      *bufio.Reader
+	binary bool
+	line   int
+}
+
+// Open detects the capture format from the file header.
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{f: f, r: bufio.NewReaderSize(f, 1<<16)}
+
+	hdr, err := r.r.Peek(headerLen)
+	if err == nil && bytes.HasPrefix(hdr, magic) {
+		if v := hdr[len(magic)]; v != 1 && v != version {
+			f.Close()
+			return nil, fmt.Errorf("%s: unsupported capture version %d", path, hdr[len(magic)])
+		}
+		// Records are decoded in host byte order like the ring buffer.
+		if hdr[len(magic)+1] != hostOrder() {
+			f.Close()
+			return nil, fmt.Errorf("%s: capture was recorded on a host with different byte order", path)
+		}
+		r.binary = true
+		r.r.Discard(headerLen)
+	}
+	return r, nil
+}
+
+// Next returns the next event, or io.EOF at the end of the capture.
+func (r *Reader) Next() (events.EventGetter, error) {
+	if r.binary {
+		return r.nextBinary()
+	}
+	return r.nextJSON()
+}
+
+func (r *Reader) nextBinary() (events.EventGetter, error) {
+	var n [4]byte
+	if _, err := io.ReadFull(r.r, n[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("truncated capture")
+		}
+		return nil, err
+	}
+	size := binary.NativeEndian.Uint32(n[:])
+	if size > maxRecord {
+		return nil, fmt.Errorf("corrupt capture: record of %d bytes", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, fmt.Errorf("truncated capture: %v", err)
+	}
+
+	hdr, err := events.ParseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	evt, ok := events.NewEvent(hdr.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %d in capture", hdr.Type)
+	}
+	if err := evt.(encoding.BinaryUnmarshaler).UnmarshalBinary(data[:hdr.Size]); err != nil {
+		return nil, err
+	}
+	if deny, ok := evt.(*events.DenyEvent); ok {
+		deny.RuleName = string(data[hdr.Size:])
+	}
+	return evt, nil
+}
+
+func (r *Reader) nextJSON() (events.EventGetter, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		r.line++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec jsonRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		t, ok := events.ParseEventType(rec.Type)
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown event type %q", r.line, rec.Type)
+		}
+		evt, _ := events.NewEvent(t)
+		if err := json.Unmarshal(rec.Event, evt); err != nil {
+			return nil, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		return evt, nil
+	}
+}
+
+func (r *Reader) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,85 @@
+package capture
+
+import (
+	"diploma/internal/events"
+	"encoding"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func sampleEvents(t *testing.T) ([]events.EventGetter, [][]byte) {
+	common := func(typ events.EventType, ts uint64) events.CommonEvent {
+		c := events.CommonEvent{
+			Header: events.EventHeader{Type: typ, TimestampNs: ts},
+			Pid:    4242,
+			Ppid:   1,
+			Uid:    1000,
+			Gid:    1000,
+		}
+		copy(c.Comm[:], "curl")
+		copy(c.Pcomm[:], "bash")
+		return c
+	}
+
+	openat := &events.OpenatEvent{Common: common(events.EventOpenat, 100), Flags: 577, Dfd: -100, Ret: 3, Filename: "syslog", Path: "/var/log/syslog"}
+	execve := &events.ExecveEvent{Common: common(events.EventExecve, 200), Argc: 2, Filename: "/usr/bin/curl", Args: []string{"curl", "-s"}, Env: []string{"HOME=/root"}}
+	deny := &events.DenyEvent{Common: common(events.EventDeny, 300), Hook: events.DenyHookFileOpen, RuleId: 1, Blocked: 1, Path: "/etc/shadow", RuleName: "shadow"}
+
+	evts := []events.EventGetter{openat, execve, deny}
+	raws := make([][]byte, len(evts))
+	for i, evt := range evts {
+		// The header size is that of the record, as the kernel sets it.
+		raw, err := events.Encode(evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := evt.(encoding.BinaryUnmarshaler).UnmarshalBinary(raw); err != nil {
+			t.Fatal(err)
+		}
+		raws[i] = raw
+	}
+	return evts, raws
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatBinary, FormatJSONL} {
+		t.Run(format, func(t *testing.T) {
+			evts, raws := sampleEvents(t)
+			path := filepath.Join(t.TempDir(), "capture")
+
+			w, err := Create(path, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, evt := range evts {
+				if err := w.Write(evt, raws[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			for i, want := range evts {
+				got, err := r.Next()
+				if err != nil {
+					t.Fatalf("event %d: %v", i, err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("event %d = %+v, want %+v", i, got, want)
+				}
+			}
+			if _, err := r.Next(); !errors.Is(err, io.EOF) {
+				t.Errorf("after the last event: %v, want io.EOF", err)
+			}
+		})
+	}
+}
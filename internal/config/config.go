@@ -2,12 +2,184 @@ package config
 
 import (
 	"diploma/internal/analyzer"
+	"diploma/internal/exeinfo"
+	"diploma/internal/ioc"
 	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	DefaultRulesPath       = "configs/security_rules.yaml"
+	DefaultRingBufferSize  = 1 << 24
+	DefaultStatsInterval   = 10 * time.Second
+	DefaultSummaryInterval = time.Minute
+	DefaultBackend         = "auto"
+
+	DefaultAuditLog     = "response_audit.jsonl"
+	DefaultMaxActions   = 5
+	DefaultActionWindow = time.Minute
+	DefaultCgroupRoot   = "/sys/fs/cgroup"
+
+	DefaultBaselinePath       = "baseline.json"
+	DefaultBaselinePeriod     = 24 * time.Hour
+	DefaultBaselineMaxEntries = 1000
+
+	DefaultExecveArgsLen = 4096
+	DefaultExecveEnvLen  = 1024
+
+	DefaultExeMaxFileSize = 64 << 20
+	DefaultExeCacheSize   = 4096
+	DefaultExeWorkers     = 2
+	DefaultExeQueueSize   = 256
+
+	DefaultIOCReloadInterval = time.Minute
+)
+
+type Config struct {
+	RulesPath      string        `yaml:"rules_path"`
+	RingBufferSize uint32        `yaml:"ring_buffer_size"`
+	StatsInterval  time.Duration `yaml:"stats_interval"`
+	MetricsAddr    string        `yaml:"metrics_addr"`
+
+	// SummaryInterval is how often summaries of suppressed alerts are sent.
+	SummaryInterval time.Duration `yaml:"summary_interval"`
+
+	// Backend is one of auto, fentry, kprobe or tracepoint.
+	Backend string `yaml:"backend"`
+	// Enforcement is off, dry_run or enforce; see loader.EnforceMode.
+	Enforcement string `yaml:"enforcement"`
+
+	OpenatPrefilter bool `yaml:"openat_prefilter"`
+
+	// EnabledEvents are attached in addition to the event types used by rules.
+	EnabledEvents []string `yaml:"enabled_events"`
+
+	Ignore IgnoreConfig `yaml:"ignore"`
+
+	Execve ExecveConfig `yaml:"execve"`
+
+	ExeInfo exeinfo.Config `yaml:"exe_info"`
+
+	Response ResponseConfig `yaml:"response"`
+
+	Baseline analyzer.BaselineConfig `yaml:"baseline"`
+
+	IOC ioc.Config `yaml:"ioc"`
+
+	Record RecordConfig `yaml:"record"`
+}
+
+// RecordConfig enables writing every event read from the ring buffer to a
+// capture file for "monitor replay".
+type RecordConfig struct {
+	// Path of the capture; empty disables recording. The file is truncated.
+	Path string `yaml:"path"`
+	// Format is binary (raw records, compact) or jsonl.
+	Format string `yaml:"format"`
+}
+
+// ExecveConfig caps the bytes of NUL-separated strings copied from each
+// execve; longer argument lists and environments are truncated.
+type ExecveConfig struct {
+	MaxArgsLen uint32 `yaml:"max_args_len"`
+	MaxEnvLen  uint32 `yaml:"max_env_len"`
+}
+
+// IgnoreConfig lists processes whose events are dropped in the kernel before
+// they are recorded.
+type IgnoreConfig struct {
+	Comms     []string `yaml:"comms"`
+	Uids      []uint32 `yaml:"uids"`
+	CgroupIds []uint64 `yaml:"cgroup_ids"`
+	ExePaths  []string `yaml:"exe_paths"`
+}
+
+// ResponseConfig controls the actions listed in the rules' actions field.
+type ResponseConfig struct {
+	// AuditLog is a JSON lines file recording every action attempted.
+	AuditLog string `yaml:"audit_log"`
+	// At most MaxActions actions per rule are executed in each Window.
+	MaxActions int           `yaml:"max_actions"`
+	Window     time.Duration `yaml:"window"`
+	// CgroupRoot is the cgroup v2 mount point.
+	CgroupRoot string `yaml:"cgroup_root"`
+}
+
+func Load(path string) (*Config, error) {
+	cfg := Config{
+		RulesPath:       DefaultRulesPath,
+		RingBufferSize:  DefaultRingBufferSize,
+		StatsInterval:   DefaultStatsInterval,
+		SummaryInterval: DefaultSummaryInterval,
+		Backend:         DefaultBackend,
+		Enforcement:     "off",
+
+		OpenatPrefilter: true,
+
+		Execve: ExecveConfig{
+			MaxArgsLen: DefaultExecveArgsLen,
+			MaxEnvLen:  DefaultExecveEnvLen,
+		},
+
+		ExeInfo: exeinfo.Config{
+			Enabled:     true,
+			MaxFileSize: DefaultExeMaxFileSize,
+			CacheSize:   DefaultExeCacheSize,
+			Workers:     DefaultExeWorkers,
+			QueueSize:   DefaultExeQueueSize,
+		},
+
+		Response: ResponseConfig{
+			AuditLog:   DefaultAuditLog,
+			MaxActions: DefaultMaxActions,
+			Window:     DefaultActionWindow,
+			CgroupRoot: DefaultCgroupRoot,
+		},
+
+		Baseline: analyzer.BaselineConfig{
+			Mode:        analyzer.BaselineOff,
+			Path:        DefaultBaselinePath,
+			LearnPeriod: DefaultBaselinePeriod,
+			Scope:       analyzer.BaselineScopeExe,
+			MaxEntries:  DefaultBaselineMaxEntries,
+			Severity:    "MEDIUM",
+		},
+
+		IOC: ioc.Config{ReloadInterval: DefaultIOCReloadInterval},
+
+		Record: RecordConfig{Format: "binary"},
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse yaml: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// validate rejects values that would otherwise fail later, e.g. intervals
+// that time.NewTicker panics on.
+func (c *Config) validate() error {
+	if c.StatsInterval <= 0 {
+		return fmt.Errorf("stats_interval must be positive, got %s", c.StatsInterval)
+	}
+	if c.SummaryInterval <= 0 {
+		return fmt.Errorf("summary_interval must be positive, got %s", c.SummaryInterval)
+	}
+	return nil
+}
+
 func LoadRules(path string) (*analyzer.RulesConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -3,7 +3,10 @@ package events
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"net"
+	"path/filepath"
+	"strings"
 )
 
 type EventGetter interface {
@@ -11,7 +14,70 @@ type EventGetter interface {
 	GetField(name string) (interface{}, bool)
 }
 
+type EventType uint32
+
+// Values must match enum event_type in trace.c.in.
+const (
+	EventOpenat  EventType = 1
+	EventExecve  EventType = 2
+	EventConnect EventType = 3
+	EventAccept  EventType = 4
+	EventPtrace  EventType = 5
+	EventMemfd   EventType = 6
+	EventChmod   EventType = 7
+	EventDeny    EventType = 8
+)
+
+// EventHeader is the common prefix of every ring buffer record.
+type EventHeader struct {
+	Type        EventType
+	Size        uint32
+	TimestampNs uint64
+}
+
+const EventHeaderSize = 16
+
+var eventTypeNames = map[EventType]string{
+	EventOpenat:  "openat",
+	EventExecve:  "execve",
+	EventConnect: "connect",
+	EventAccept:  "accept",
+	EventPtrace:  "ptrace",
+	EventMemfd:   "memfd_create",
+	EventChmod:   "chmod",
+	EventDeny:    "deny",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", uint32(t))
+}
+
+func ParseEventType(name string) (EventType, bool) {
+	for t, n := range eventTypeNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+func ParseHeader(data []byte) (EventHeader, error) {
+	if len(data) < EventHeaderSize {
+		return EventHeader{}, fmt.Errorf("record too short for header: %d bytes", len(data))
+	}
+	var hdr EventHeader
+	hdr.unmarshal(data)
+	if int(hdr.Size) > len(data) {
+		return hdr, fmt.Errorf("%s record truncated: header size %d, got %d bytes", hdr.Type, hdr.Size, len(data))
+	}
+	return hdr, nil
+}
+
 type CommonEvent struct {
+	Header   EventHeader
 	CgroupId uint64
 	Pid      uint32
 	Ppid     uint32
@@ -21,20 +87,70 @@ type CommonEvent struct {
 	Pcomm    [16]byte
 }
 
+// AtFdcwd as a dfd makes a relative name relative to the working directory.
+const AtFdcwd = -100
+
 type OpenatEvent struct {
-	Common   CommonEvent
-	Flags    int32
-	Dfd      int32
-	Ret      int32
-	Filename [128]byte
+	Common CommonEvent
+	Flags  int32
+	Dfd    int32
+	Ret    int32
+	// Filename is the name as passed to openat. Path is the absolute path of
+	// the opened file resolved in the kernel, empty if the call failed or the
+	// file was nested too deep. Only then, for a relative Filename, Dir is the
+	// directory it is relative to: the working directory or Dfd.
+	Filename string
+	Path     string
+	Dir      string
 }
 
+// Bits of ExecveEvent.Flags.
+const (
+	ExecveArgsTruncated = 1 << 0
+	ExecveEnvTruncated  = 1 << 1
+)
+
 type ExecveEvent struct {
 	Common   CommonEvent
 	Ret      int32
-	Filename [128]byte
-	Argv     [24][64]byte
-	Envp     [24][64]byte
+	Argc     uint32
+	Flags    uint32
+	Filename string
+	// Args and Env come from the variable-length end of the record. Argc
+	// may exceed len(Args) when the arguments were truncated.
+	Args []string
+	Env  []string
+	// Exe is filled in by the analyzer from the executed file, nil if it
+	// could not be read. Captures never hold it.
+	Exe *ExeInfo `json:",omitempty"`
+}
+
+// ExeInfo describes an executed file.
+type ExeInfo struct {
+	SHA256   string
+	OwnerUid uint32
+	OwnerGid uint32
+	// Mode holds the permission bits, including setuid, setgid and sticky.
+	Mode uint32
+	ELF  bool
+	// Static is set for an ELF file without a program interpreter.
+	// Interpreter is the ELF interpreter, or the #! line's for a script.
+	Static      bool
+	Interpreter string
+	Stripped    bool
+	Packed      bool
+}
+
+// WritableBy reports whether the permission bits let uid or gid write the
+// file, ignoring root's override and supplementary groups.
+func (i *ExeInfo) WritableBy(uid, gid uint32) bool {
+	switch {
+	case i.OwnerUid == uid:
+		return i.Mode&0o200 != 0
+	case i.OwnerGid == gid:
+		return i.Mode&0o020 != 0
+	}
+	return i.Mode&0o002 != 0
 }
 
 type ConnectEvent struct {
@@ -70,10 +186,41 @@ type MemfdEvent struct {
 }
 
 type ChmodEvent struct {
-	Common   CommonEvent
-	Ret      int32
-	Mode     uint32
-	Filename [128]byte
+	Common CommonEvent
+	Ret    int32
+	Mode   uint32
+	Dfd    int32
+	// Dir is the directory a relative Filename is relative to, resolved in
+	// the kernel from the working directory or Dfd.
+	Filename string
+	Dir      string
+}
+
+// Values must match enum deny_hook in trace.c.in.
+const (
+	DenyHookFileOpen      = 1
+	DenyHookBprmCheck     = 2
+	DenyHookSocketConnect = 3
+	DenyHookPtrace        = 4
+)
+
+// DenyEvent is reported by the LSM programs when an operation matches a deny
+// rule. Blocked is 0 in dry-run mode.
+type DenyEvent struct {
+	Common    CommonEvent
+	Hook      uint32
+	RuleId    uint32
+	Blocked   uint32
+	Ip        uint32
+	Port      uint16
+	Pad       uint16
+	TargetPid int32
+	// Path is the checked file's path, resolved in the kernel; empty for
+	// socket_connect and ptrace_access_check.
+	Path string
+	// RuleName is the rule RuleId stands for. It is not part of the record:
+	// the monitor sets it on decoding, so that captures keep it.
+	RuleName string
 }
 
 // --- String() ---
@@ -88,21 +235,33 @@ func BytesToString(data []byte) string {
 
 func IntToIP(nn uint32) string {
 	ip := make(net.IP, 4)
-	binary.LittleEndian.PutUint32(ip, nn)
+	native.PutUint32(ip, nn)
 	return ip.String()
 }
 
 func Ntohs(port uint16) uint16 {
-	return (port<<8)&0xff00 | (port>>8)&0x00ff
+	var b [2]byte
+	native.PutUint16(b[:], port)
+	return binary.BigEndian.Uint16(b[:])
 }
 
-func ExtractArgs(raw [24][64]byte) []string {
-	var res []string
-	for _, chunk := range raw {
-		str := BytesToString(chunk[:])
-		if str != "" {
-			res = append(res, str)
-		}
+// JoinDir returns name relative to dir, as sent by the kernel for relative
+// names, or name itself if it is absolute or dir is unknown.
+func JoinDir(dir, name string) string {
+	if dir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+// SplitStrings splits NUL-terminated strings. A last string cut off before
+// its NUL is kept.
+func SplitStrings(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	if data[len(data)-1] == 0 {
+		data = data[:len(data)-1]
 	}
-	return res
+	return strings.Split(string(data), "\x00")
 }
@@ -0,0 +1,141 @@
+package events
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FromFields builds an event of the named type whose getters return the given
+// field values, e.g. for rule fixtures. Fields missing from the map are zero;
+// unknown fields are an error.
+func FromFields(eventType string, fields map[string]string) (EventGetter, error) {
+	t, ok := ParseEventType(eventType)
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", eventType)
+	}
+	evt, _ := NewEvent(t)
+
+	// Sorted for stable error messages.
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f, _, ok := registry[t].lookup(name)
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("%s field %s: unknown field", eventType, name)
+		case f.set == nil:
+			return nil, fmt.Errorf("%s field %s: derived from other fields, cannot be set", eventType, name)
+		}
+		if err := f.set(evt, fields[name]); err != nil {
+			return nil, fmt.Errorf("%s field %s: %v", eventType, name, err)
+		}
+	}
+	return evt, nil
+}
+
+// setString stores value NUL-terminated, as the kernel does.
+func setString(dst []byte, value string) error {
+	if len(value) >= len(dst) {
+		return fmt.Errorf("longer than %d bytes", len(dst)-1)
+	}
+	clear(dst)
+	copy(dst, value)
+	return nil
+}
+
+// pathMax is PATH_MAX in trace.c.in, the longest name the kernel sends.
+const pathMax = 4096
+
+func setPath(dst *string, value string) error {
+	if len(value) >= pathMax {
+		return fmt.Errorf("longer than %d bytes", pathMax-1)
+	}
+	*dst = value
+	return nil
+}
+
+func setInt32(dst *int32, value string) error {
+	n, err := strconv.ParseInt(value, 10, 32)
+	*dst = int32(n)
+	return err
+}
+
+func parseUint32(value string) (uint32, error) {
+	n, err := strconv.ParseUint(value, 10, 32)
+	return uint32(n), err
+}
+
+func setOpenFlags(dst *int32, value string) error {
+	var flags uint32
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "O_RDONLY" {
+			continue
+		}
+		mask, ok := OpenFlagMask(name)
+		if !ok {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		flags |= mask
+	}
+	*dst = int32(flags)
+	return nil
+}
+
+// setArgs splits value at spaces, so fixture arguments cannot contain any.
+func setArgs(e *ExecveEvent, value string) error {
+	e.Args = strings.Fields(value)
+	e.Argc = uint32(len(e.Args))
+	return nil
+}
+
+func setFlag(dst *uint32, bit uint32, value string) error {
+	set, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if set {
+		*dst |= bit
+	} else {
+		*dst &^= bit
+	}
+	return nil
+}
+
+func setBool(dst *bool, value string) (err error) {
+	*dst, err = strconv.ParseBool(value)
+	return
+}
+
+// exeInfo returns e.Exe for a fixture to fill in, allocating it if needed.
+func exeInfo(e *ExecveEvent) *ExeInfo {
+	if e.Exe == nil {
+		e.Exe = &ExeInfo{}
+	}
+	return e.Exe
+}
+
+func setIP(dst *uint32, value string) error {
+	ip := net.ParseIP(value).To4()
+	if ip == nil {
+		return fmt.Errorf("not an IPv4 address: %q", value)
+	}
+	*dst = native.Uint32(ip)
+	return nil
+}
+
+func setPort(dst *uint16, value string) error {
+	n, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return err
+	}
+	*dst = Ntohs(uint16(n))
+	return nil
+}
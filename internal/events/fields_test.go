@@ -0,0 +1,70 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFromFieldsRoundTrip(t *testing.T) {
+	cases := map[string]map[string]string{
+		"openat": {
+			"fd.name":       "/etc/shadow",
+			"evt.arg.flags": "O_WRONLY,O_CREAT,O_TRUNC",
+			"evt.res":       "-13",
+			"proc.pid":      "42",
+			"proc.name":     "cat",
+			"proc.cgroup":   "9892",
+		},
+		"execve": {
+			"proc.exepath": "/usr/bin/nc",
+			"proc.args":    "nc -e /bin/sh",
+			"proc.env":     "HOME=/root",
+			"proc.pname":   "bash",
+			"proc.uid":     "33",
+		},
+		"connect":      {"fd.ip": "10.0.0.1", "fd.port": "6443", "fd.num": "5", "evt.res": "-115"},
+		"accept":       {"fd.ip": "127.0.0.1", "fd.port": "8000", "evt.res": "6"},
+		"ptrace":       {"evt.arg.request": "PTRACE_ATTACH", "proc.target_pid": "1", "evt.arg.addr": "0xdeadbeef"},
+		"memfd_create": {"evt.arg.name": "payload", "evt.arg.flags": "1", "evt.res": "4"},
+		"chmod":        {"fd.name": "/tmp/x", "evt.arg.mode": "04755"},
+		"deny":         {"fd.name": "/dev/shm/x", "fd.port": "22", "proc.target_pid": "7"},
+	}
+
+	for eventType, fields := range cases {
+		t.Run(eventType, func(t *testing.T) {
+			evt, err := FromFields(eventType, fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if evt.GetType() != eventType {
+				t.Fatalf("type %s, want %s", evt.GetType(), eventType)
+			}
+			for name, want := range fields {
+				got, ok := evt.GetField(name)
+				if !ok || fmt.Sprint(got) != want {
+					t.Errorf("%s = %v, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFromFieldsErrors(t *testing.T) {
+	cases := []struct {
+		eventType string
+		fields    map[string]string
+	}{
+		{"bogus", nil},
+		{"openat", map[string]string{"fd.nmae": "/x"}},
+		{"openat", map[string]string{"evt.arg.flags": "O_BOGUS"}},
+		{"connect", map[string]string{"fd.ip": "::1"}},
+		{"connect", map[string]string{"fd.port": "70000"}},
+		{"chmod", map[string]string{"evt.arg.mode": "9"}},
+		{"memfd_create", map[string]string{"evt.arg.name": string(make([]byte, 128))}},
+	}
+	for _, tc := range cases {
+		if _, err := FromFields(tc.eventType, tc.fields); err == nil {
+			t.Errorf("FromFields(%s, %v): expected error", tc.eventType, tc.fields)
+		}
+	}
+}
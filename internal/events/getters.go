@@ -2,7 +2,7 @@ package events
 
 import (
 	"fmt"
-	"log"
+	"strconv"
 	"strings"
 	"syscall"
 )
@@ -53,6 +53,26 @@ func decodeOpenFlags(flags int32) []string {
 	return res
 }
 
+// openFlagMasks maps flag names produced by decodeOpenFlags to bits that are
+// set whenever the name is present. O_RDONLY has no bits and is omitted.
+var openFlagMasks = map[string]uint32{
+	"O_WRONLY":   syscall.O_WRONLY,
+	"O_RDWR":     syscall.O_RDWR,
+	"O_CREAT":    syscall.O_CREAT,
+	"O_EXCL":     syscall.O_EXCL,
+	"O_NOCTTY":   syscall.O_NOCTTY,
+	"O_TRUNC":    syscall.O_TRUNC,
+	"O_APPEND":   syscall.O_APPEND,
+	"O_NONBLOCK": syscall.O_NONBLOCK,
+	"O_DSYNC":    syscall.O_DSYNC,
+	"O_CLOEXEC":  syscall.O_CLOEXEC,
+}
+
+func OpenFlagMask(name string) (uint32, bool) {
+	mask, ok := openFlagMasks[name]
+	return mask, ok
+}
+
 var ptraceRequests = map[uint64]string{
 	0:  "PTRACE_TRACEME",
 	1:  "PTRACE_PEEKTEXT",
@@ -79,160 +99,338 @@ var ptraceRequests = map[uint64]string{
 	0x420F: "PTRACE_SETREGSET",
 }
 
-func getCommonField(c *CommonEvent, name string) (interface{}, bool) {
-	switch name {
-	case "proc.pid":
-		return int(c.Pid), true
-	case "proc.ppid":
-		return int(c.Ppid), true
-	case "proc.uid":
-		return int(c.Uid), true
-	case "proc.gid":
-		return int(c.Gid), true
-	case "proc.cgroup":
-		return int(c.CgroupId), true
-	case "proc.name":
-		return BytesToString(c.Comm[:]), true
-	case "proc.pname":
-		return BytesToString(c.Pcomm[:]), true
+// fdFromRet returns the descriptor created by a syscall, or -1 if it failed.
+func fdFromRet(ret int32) int {
+	if ret < 0 {
+		return -1
 	}
-	return nil, false
+	return int(ret)
 }
 
-// --- OpenatEvent ---
-
-func (e *OpenatEvent) GetType() string {
-	return "openat"
+var commonFields = []Field{
+	field("proc.pid", FieldInt, "Process (thread group) id.",
+		func(c *CommonEvent) any { return int(c.Pid) },
+		func(c *CommonEvent, v string) (err error) { c.Pid, err = parseUint32(v); return }),
+	field("proc.ppid", FieldInt, "Parent process id.",
+		func(c *CommonEvent) any { return int(c.Ppid) },
+		func(c *CommonEvent, v string) (err error) { c.Ppid, err = parseUint32(v); return }),
+	field("proc.uid", FieldInt, "Real user id.",
+		func(c *CommonEvent) any { return int(c.Uid) },
+		func(c *CommonEvent, v string) (err error) { c.Uid, err = parseUint32(v); return }),
+	field("proc.gid", FieldInt, "Real group id.",
+		func(c *CommonEvent) any { return int(c.Gid) },
+		func(c *CommonEvent, v string) (err error) { c.Gid, err = parseUint32(v); return }),
+	field("proc.cgroup", FieldInt, "Cgroup v2 id of the process.",
+		func(c *CommonEvent) any { return int(c.CgroupId) },
+		func(c *CommonEvent, v string) (err error) { c.CgroupId, err = strconv.ParseUint(v, 10, 64); return }),
+	field("proc.name", FieldString, "Command name (comm), at most 15 bytes.",
+		func(c *CommonEvent) any { return BytesToString(c.Comm[:]) },
+		func(c *CommonEvent, v string) error { return setString(c.Comm[:], v) }),
+	field("proc.pname", FieldString, "Command name of the parent process.",
+		func(c *CommonEvent) any { return BytesToString(c.Pcomm[:]) },
+		func(c *CommonEvent, v string) error { return setString(c.Pcomm[:], v) }),
+	field("evt.ts", FieldInt, "Kernel monotonic time of the event, ns.",
+		func(c *CommonEvent) any { return int64(c.Header.TimestampNs) },
+		func(c *CommonEvent, v string) (err error) {
+			c.Header.TimestampNs, err = strconv.ParseUint(v, 10, 64)
+			return
+		}),
 }
 
-func (e *OpenatEvent) GetField(name string) (interface{}, bool) {
-	switch name {
-	case "fd.name", "evt.arg.filename":
-		return BytesToString(e.Filename[:]), true
-	case "evt.arg.flags":
-		flags := decodeOpenFlags(e.Flags)
-		return strings.Join(flags, ","), true
-	case "evt.res", "fd.num":
-		return int(e.Ret), true
-	}
-
-	return getCommonField(&e.Common, name)
+var openatFields = []Field{
+	pathField("fd.name", "Opened file, absolute: resolved in the kernel, or by the analyzer if the call failed.",
+		func(e *OpenatEvent) any {
+			if e.Path != "" {
+				return e.Path
+			}
+			return JoinDir(e.Dir, e.Filename)
+		},
+		func(e *OpenatEvent, v string) error { return setPath(&e.Path, v) }),
+	alias("evt.arg.filename", "fd.name"),
+	field("evt.rawarg.filename", FieldString, "Name as passed to openat, possibly relative.",
+		func(e *OpenatEvent) any { return e.Filename },
+		func(e *OpenatEvent, v string) error { return setPath(&e.Filename, v) }),
+	field("evt.arg.dfd", FieldInt, "Directory descriptor of a relative name, -100 for the working directory.",
+		func(e *OpenatEvent) any { return int(e.Dfd) },
+		func(e *OpenatEvent, v string) error { return setInt32(&e.Dfd, v) }),
+	field("evt.arg.flags", FieldString, "Open flags, e.g. O_WRONLY,O_CREAT.",
+		func(e *OpenatEvent) any { return strings.Join(decodeOpenFlags(e.Flags), ",") },
+		func(e *OpenatEvent, v string) error { return setOpenFlags(&e.Flags, v) }),
+	field("evt.res", FieldInt, "Return value: new descriptor or -errno.",
+		func(e *OpenatEvent) any { return int(e.Ret) },
+		func(e *OpenatEvent, v string) error { return setInt32(&e.Ret, v) }),
+	field("fd.num", FieldInt, "Opened descriptor, -1 if the call failed.",
+		func(e *OpenatEvent) any { return fdFromRet(e.Ret) }, nil),
 }
 
-// --- ExecveEvent ---
-
-func (e *ExecveEvent) GetType() string {
-	return "execve"
+var execveFields = []Field{
+	pathField("proc.exepath", "Executed file; absolute once resolved by the analyzer.",
+		func(e *ExecveEvent) any { return e.Filename },
+		func(e *ExecveEvent, v string) error { return setPath(&e.Filename, v) }),
+	alias("evt.arg.filename", "proc.exepath"),
+	field("proc.cmdline", FieldString, "Arguments joined by spaces, argv[0] first.",
+		func(e *ExecveEvent) any { return strings.Join(e.Args, " ") },
+		func(e *ExecveEvent, v string) error { return setArgs(e, v) }),
+	alias("proc.args", "proc.cmdline"),
+	indexedField("proc.args", "Argument N, proc.args[0] being argv[0]; empty if missing or truncated.",
+		func(e *ExecveEvent, i int) any {
+			if i < len(e.Args) {
+				return e.Args[i]
+			}
+			return ""
+		}),
+	field("proc.argc", FieldInt, "Number of arguments, including any truncated.",
+		func(e *ExecveEvent) any { return int(e.Argc) },
+		func(e *ExecveEvent, v string) (err error) { e.Argc, err = parseUint32(v); return }),
+	field("proc.args_truncated", FieldBool, "The arguments were cut off at execve.max_args_len bytes.",
+		func(e *ExecveEvent) any { return e.Flags&ExecveArgsTruncated != 0 },
+		func(e *ExecveEvent, v string) error { return setFlag(&e.Flags, ExecveArgsTruncated, v) }),
+	field("proc.env", FieldString, "Environment joined by spaces.",
+		func(e *ExecveEvent) any { return strings.Join(e.Env, " ") },
+		func(e *ExecveEvent, v string) error { e.Env = strings.Fields(v); return nil }),
+	field("proc.env_truncated", FieldBool, "The environment was cut off at execve.max_env_len bytes.",
+		func(e *ExecveEvent) any { return e.Flags&ExecveEnvTruncated != 0 },
+		func(e *ExecveEvent, v string) error { return setFlag(&e.Flags, ExecveEnvTruncated, v) }),
+	field("proc.exe.sha256", FieldString, "SHA-256 of the executed file in hex; empty if it could not be read or is over exe_info.max_file_size.",
+		func(e *ExecveEvent) any {
+			if e.Exe == nil {
+				return ""
+			}
+			return e.Exe.SHA256
+		},
+		func(e *ExecveEvent, v string) error { exeInfo(e).SHA256 = strings.ToLower(v); return nil }),
+	field("proc.exe.owner_uid", FieldInt, "Owner of the executed file, -1 if unknown.",
+		func(e *ExecveEvent) any {
+			if e.Exe == nil {
+				return -1
+			}
+			return int(e.Exe.OwnerUid)
+		},
+		func(e *ExecveEvent, v string) (err error) { exeInfo(e).OwnerUid, err = parseUint32(v); return }),
+	field("proc.exe.owner_gid", FieldInt, "Group of the executed file, -1 if unknown.",
+		func(e *ExecveEvent) any {
+			if e.Exe == nil {
+				return -1
+			}
+			return int(e.Exe.OwnerGid)
+		},
+		func(e *ExecveEvent, v string) (err error) { exeInfo(e).OwnerGid, err = parseUint32(v); return }),
+	field("proc.exe.mode", FieldString, "Permission bits of the executed file in octal, e.g. 04755; empty if unknown.",
+		func(e *ExecveEvent) any {
+			if e.Exe == nil {
+				return ""
+			}
+			return fmt.Sprintf("0%o", e.Exe.Mode)
+		},
+		func(e *ExecveEvent, v string) error {
+			n, err := strconv.ParseUint(v, 8, 32)
+			exeInfo(e).Mode = uint32(n)
+			return err
+		}),
+	field("proc.exe.writable_by_user", FieldBool, "The permission bits let the process's uid or gid modify the executed file.",
+		func(e *ExecveEvent) any { return e.Exe != nil && e.Exe.WritableBy(e.Common.Uid, e.Common.Gid) }, nil),
+	field("proc.exe.elf", FieldBool, "The executed file is an ELF binary.",
+		func(e *ExecveEvent) any { return e.Exe != nil && e.Exe.ELF },
+		func(e *ExecveEvent, v string) error { return setBool(&exeInfo(e).ELF, v) }),
+	field("proc.exe.static", FieldBool, "The executed ELF binary has no program interpreter.",
+		func(e *ExecveEvent) any { return e.Exe != nil && e.Exe.Static },
+		func(e *ExecveEvent, v string) error { return setBool(&exeInfo(e).Static, v) }),
+	field("proc.exe.interpreter", FieldString, "ELF program interpreter, or the interpreter of a #! script.",
+		func(e *ExecveEvent) any {
+			if e.Exe == nil {
+				return ""
+			}
+			return e.Exe.Interpreter
+		},
+		func(e *ExecveEvent, v string) error { return setPath(&exeInfo(e).Interpreter, v) }),
+	field("proc.exe.stripped", FieldBool, "The executed ELF binary has no symbol table.",
+		func(e *ExecveEvent) any { return e.Exe != nil && e.Exe.Stripped },
+		func(e *ExecveEvent, v string) error { return setBool(&exeInfo(e).Stripped, v) }),
+	field("proc.exe.packed", FieldBool, "The executed ELF binary looks packed: a UPX marker or no section headers.",
+		func(e *ExecveEvent) any { return e.Exe != nil && e.Exe.Packed },
+		func(e *ExecveEvent, v string) error { return setBool(&exeInfo(e).Packed, v) }),
+	field("evt.res", FieldInt, "Return value: 0 or -errno.",
+		func(e *ExecveEvent) any { return int(e.Ret) },
+		func(e *ExecveEvent, v string) error { return setInt32(&e.Ret, v) }),
 }
 
-func (e *ExecveEvent) GetField(name string) (interface{}, bool) {
-	switch name {
-	case "proc.exepath", "evt.arg.filename":
-		return BytesToString(e.Filename[:]), true
-	case "proc.cmdline", "proc.args":
-		args := ExtractArgs(e.Argv)
-		return strings.Join(args, " "), true
-	case "proc.env":
-		envs := ExtractArgs(e.Envp)
-		return strings.Join(envs, " "), true
-	case "evt.res":
-		return int(e.Ret), true
-	}
-	return getCommonField(&e.Common, name)
+var connectFields = []Field{
+	field("fd.num", FieldInt, "Connecting socket descriptor.",
+		func(e *ConnectEvent) any { return int(e.Fd) },
+		func(e *ConnectEvent, v string) error { return setInt32(&e.Fd, v) }),
+	field("fd.ip", FieldString, "Server IPv4 address.",
+		func(e *ConnectEvent) any { return IntToIP(e.Ip) },
+		func(e *ConnectEvent, v string) error { return setIP(&e.Ip, v) }),
+	alias("fd.sip", "fd.ip"),
+	field("fd.port", FieldInt, "Server port.",
+		func(e *ConnectEvent) any { return int(Ntohs(e.Port)) },
+		func(e *ConnectEvent, v string) error { return setPort(&e.Port, v) }),
+	alias("fd.sport", "fd.port"),
+	field("evt.res", FieldInt, "Return value: 0 or -errno (-115 for non-blocking sockets).",
+		func(e *ConnectEvent) any { return int(e.Ret) },
+		func(e *ConnectEvent, v string) error { return setInt32(&e.Ret, v) }),
 }
 
-// --- ConnectEvent ---
-
-func (e *ConnectEvent) GetType() string {
-	return "connect"
+var acceptFields = []Field{
+	field("fd.num", FieldInt, "Accepted socket descriptor, -1 if the call failed.",
+		func(e *AcceptEvent) any { return fdFromRet(e.Ret) }, nil),
+	field("fd.ip", FieldString, "Remote IPv4 address.",
+		func(e *AcceptEvent) any { return IntToIP(e.Ip) },
+		func(e *AcceptEvent, v string) error { return setIP(&e.Ip, v) }),
+	alias("fd.rip", "fd.ip"),
+	field("fd.port", FieldInt, "Remote port.",
+		func(e *AcceptEvent) any { return int(Ntohs(e.Port)) },
+		func(e *AcceptEvent, v string) error { return setPort(&e.Port, v) }),
+	alias("fd.rport", "fd.port"),
+	field("evt.res", FieldInt, "Return value: new descriptor or -errno.",
+		func(e *AcceptEvent) any { return int(e.Ret) },
+		func(e *AcceptEvent, v string) error { return setInt32(&e.Ret, v) }),
 }
 
-func (e *ConnectEvent) GetField(name string) (interface{}, bool) {
-	switch name {
-	case "fd.num":
-		return int(e.Fd), true
-	case "fd.ip", "fd.sip": // Server IP
-		return IntToIP(e.Ip), true
-	case "fd.port", "fd.sport": // Server Port
-		return int(Ntohs(e.Port)), true
-	case "evt.res":
-		return int(e.Ret), true
-	}
-	return getCommonField(&e.Common, name)
+var ptraceFields = []Field{
+	field("evt.arg.request", FieldString, "Request name, e.g. PTRACE_ATTACH, or its number if unknown.",
+		func(e *PtraceEvent) any {
+			if name, ok := ptraceRequests[e.Request]; ok {
+				return name
+			}
+			return strconv.FormatUint(e.Request, 10)
+		},
+		func(e *PtraceEvent, v string) (err error) {
+			for req, name := range ptraceRequests {
+				if name == v {
+					e.Request = req
+					return nil
+				}
+			}
+			e.Request, err = strconv.ParseUint(v, 0, 64)
+			return err
+		}),
+	field("proc.target_pid", FieldInt, "Traced process id.",
+		func(e *PtraceEvent) any { return int(e.TargetPid) },
+		func(e *PtraceEvent, v string) error { return setInt32(&e.TargetPid, v) }),
+	field("evt.arg.addr", FieldString, "Address argument in hex.",
+		func(e *PtraceEvent) any { return fmt.Sprintf("0x%x", e.Addr) }, // Адрес лучше отдавать строкой
+		func(e *PtraceEvent, v string) (err error) { e.Addr, err = strconv.ParseUint(v, 0, 64); return }),
+	field("evt.res", FieldInt, "Return value.",
+		func(e *PtraceEvent) any { return int(e.Ret) },
+		func(e *PtraceEvent, v string) error { return setInt32(&e.Ret, v) }),
 }
 
-// --- AcceptEvent ---
-
-func (e *AcceptEvent) GetType() string {
-	return "accept"
+var memfdFields = []Field{
+	field("evt.arg.name", FieldString, "Name given to the memfd.",
+		func(e *MemfdEvent) any { return BytesToString(e.Name[:]) },
+		func(e *MemfdEvent, v string) error { return setString(e.Name[:], v) }),
+	field("evt.arg.flags", FieldInt, "MFD_* flags.",
+		func(e *MemfdEvent) any { return int(e.Flags) },
+		func(e *MemfdEvent, v string) error {
+			n, err := strconv.ParseUint(v, 0, 32)
+			e.Flags = uint32(n)
+			return err
+		}),
+	field("evt.res", FieldInt, "Return value: new descriptor or -errno.",
+		func(e *MemfdEvent) any { return int(e.Ret) },
+		func(e *MemfdEvent, v string) error { return setInt32(&e.Ret, v) }),
+	field("fd.num", FieldInt, "Created descriptor, -1 if the call failed.",
+		func(e *MemfdEvent) any { return fdFromRet(e.Ret) }, nil),
 }
 
-func (e *AcceptEvent) GetField(name string) (interface{}, bool) {
-	switch name {
-	case "fd.num", "evt.res":
-		return int(e.Ret), true
-	case "fd.ip", "fd.rip":
-		return IntToIP(e.Ip), true
-	case "fd.port", "fd.rport": // Remote Port
-		return int(Ntohs(e.Port)), true
-	}
-	return getCommonField(&e.Common, name)
+var chmodFields = []Field{
+	pathField("fd.name", "Changed file; absolute once resolved by the analyzer.",
+		func(e *ChmodEvent) any { return JoinDir(e.Dir, e.Filename) },
+		func(e *ChmodEvent, v string) error { return setPath(&e.Filename, v) }),
+	alias("evt.arg.filename", "fd.name"),
+	field("evt.arg.dfd", FieldInt, "Directory descriptor of a relative name, -100 for the working directory.",
+		func(e *ChmodEvent) any { return int(e.Dfd) },
+		func(e *ChmodEvent, v string) error { return setInt32(&e.Dfd, v) }),
+	field("evt.arg.mode", FieldString, "New mode in octal with a leading 0, e.g. 04755.",
+		func(e *ChmodEvent) any { return fmt.Sprintf("0%o", e.Mode) },
+		func(e *ChmodEvent, v string) error {
+			n, err := strconv.ParseUint(v, 8, 32)
+			e.Mode = uint32(n)
+			return err
+		}),
+	field("evt.res", FieldInt, "Return value: 0 or -errno.",
+		func(e *ChmodEvent) any { return int(e.Ret) },
+		func(e *ChmodEvent, v string) error { return setInt32(&e.Ret, v) }),
 }
 
-// --- PtraceEvent ---
-
-func (e *PtraceEvent) GetType() string {
-	return "ptrace"
+var denyHooks = map[uint32]string{
+	DenyHookFileOpen:      "file_open",
+	DenyHookBprmCheck:     "bprm_check_security",
+	DenyHookSocketConnect: "socket_connect",
+	DenyHookPtrace:        "ptrace_access_check",
 }
 
-func (e *PtraceEvent) GetField(name string) (interface{}, bool) {
-	switch name {
-	case "evt.arg.request":
-		if name, ok := ptraceRequests[e.Request]; ok {
-			log.Printf("%s", name)
-			return name, true
-		}
-		return int(e.Request), true
-	case "proc.target_pid":
-		return int(e.TargetPid), true
-	case "evt.arg.addr":
-		return fmt.Sprintf("0x%x", e.Addr), true // Адрес лучше отдавать строкой
-	case "evt.res":
-		return int(e.Ret), true
+func (e *DenyEvent) HookName() string {
+	if name, ok := denyHooks[e.Hook]; ok {
+		return name
 	}
-	return getCommonField(&e.Common, name)
+	return fmt.Sprintf("hook(%d)", e.Hook)
 }
 
-func (e *MemfdEvent) GetType() string {
-	return "memfd_create"
+var denyFields = []Field{
+	field("evt.hook", FieldString, "LSM hook that matched, e.g. file_open.",
+		func(e *DenyEvent) any { return e.HookName() }, nil),
+	field("evt.blocked", FieldBool, "Whether the operation was blocked (false in dry-run mode).",
+		func(e *DenyEvent) any { return e.Blocked != 0 }, nil),
+	field("fd.name", FieldString, "Path of the opened or executed file, from the kernel.",
+		func(e *DenyEvent) any { return e.Path },
+		func(e *DenyEvent, v string) error { return setPath(&e.Path, v) }),
+	field("fd.ip", FieldString, "Server IPv4 address for socket_connect.",
+		func(e *DenyEvent) any { return IntToIP(e.Ip) },
+		func(e *DenyEvent, v string) error { return setIP(&e.Ip, v) }),
+	field("fd.port", FieldInt, "Server port for socket_connect.",
+		func(e *DenyEvent) any { return int(Ntohs(e.Port)) },
+		func(e *DenyEvent, v string) error { return setPort(&e.Port, v) }),
+	field("proc.target_pid", FieldInt, "Traced process id for ptrace_access_check.",
+		func(e *DenyEvent) any { return int(e.TargetPid) },
+		func(e *DenyEvent, v string) error { return setInt32(&e.TargetPid, v) }),
 }
 
-func (e *MemfdEvent) GetField(name string) (interface{}, bool) {
-	switch name {
-	case "evt.arg.name":
-		return BytesToString(e.Name[:]), true
-	case "evt.arg.flags":
-		return int(e.Flags), true
-	case "evt.res", "fd.num":
-		return int(e.Ret), true
-	}
-	return getCommonField(&e.Common, name)
+func init() {
+	registerFields(EventOpenat, openatFields)
+	registerFields(EventExecve, execveFields)
+	registerFields(EventConnect, connectFields)
+	registerFields(EventAccept, acceptFields)
+	registerFields(EventPtrace, ptraceFields)
+	registerFields(EventMemfd, memfdFields)
+	registerFields(EventChmod, chmodFields)
+	registerFields(EventDeny, denyFields)
 }
 
-// --- ChmodEvent ---
+func (e *OpenatEvent) GetType() string  { return "openat" }
+func (e *ExecveEvent) GetType() string  { return "execve" }
+func (e *ConnectEvent) GetType() string { return "connect" }
+func (e *AcceptEvent) GetType() string  { return "accept" }
+func (e *PtraceEvent) GetType() string  { return "ptrace" }
+func (e *MemfdEvent) GetType() string   { return "memfd_create" }
+func (e *ChmodEvent) GetType() string   { return "chmod" }
+func (e *DenyEvent) GetType() string    { return "deny" }
 
-func (e *ChmodEvent) GetType() string {
-	return "chmod"
+func (e *OpenatEvent) GetField(name string) (interface{}, bool) {
+	return getField(EventOpenat, e, name)
 }
-
-func (e *ChmodEvent) GetField(name string) (interface{}, bool) {
-	switch name {
-	case "fd.name", "evt.arg.filename":
-		return BytesToString(e.Filename[:]), true
-	case "evt.arg.mode":
-		return fmt.Sprintf("0%o", e.Mode), true
-	case "evt.res":
-		return int(e.Ret), true
-	}
-	return getCommonField(&e.Common, name)
+func (e *ExecveEvent) GetField(name string) (interface{}, bool) {
+	return getField(EventExecve, e, name)
 }
+func (e *ConnectEvent) GetField(name string) (interface{}, bool) {
+	return getField(EventConnect, e, name)
+}
+func (e *AcceptEvent) GetField(name string) (interface{}, bool) {
+	return getField(EventAccept, e, name)
+}
+func (e *PtraceEvent) GetField(name string) (interface{}, bool) {
+	return getField(EventPtrace, e, name)
+}
+func (e *MemfdEvent) GetField(name string) (interface{}, bool) { return getField(EventMemfd, e, name) }
+func (e *ChmodEvent) GetField(name string) (interface{}, bool) { return getField(EventChmod, e, name) }
+func (e *DenyEvent) GetField(name string) (interface{}, bool)  { return getField(EventDeny, e, name) }
+
+func (e *OpenatEvent) common() *CommonEvent  { return &e.Common }
+func (e *ExecveEvent) common() *CommonEvent  { return &e.Common }
+func (e *ConnectEvent) common() *CommonEvent { return &e.Common }
+func (e *AcceptEvent) common() *CommonEvent  { return &e.Common }
+func (e *PtraceEvent) common() *CommonEvent  { return &e.Common }
+func (e *MemfdEvent) common() *CommonEvent   { return &e.Common }
+func (e *ChmodEvent) common() *CommonEvent   { return &e.Common }
+func (e *DenyEvent) common() *CommonEvent    { return &e.Common }
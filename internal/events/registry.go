@@ -0,0 +1,197 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type FieldType string
+
+const (
+	FieldString FieldType = "string"
+	FieldInt    FieldType = "int"
+	FieldBool   FieldType = "bool"
+)
+
+// Field describes one field that rules can use on an event type.
+type Field struct {
+	Name        string
+	Type        FieldType
+	Description string
+	// Path marks the field the analyzer replaces with the resolved absolute
+	// path of the file.
+	Path bool
+	// AliasOf names the field this one is another name for.
+	AliasOf string
+
+	get   func(event any) any
+	set   func(event any, value string) error
+	index func(event any, i int) any
+}
+
+// field declares a field read from (and optionally written to) an event
+// struct T, or CommonEvent for the fields shared by all types.
+func field[T any](name string, typ FieldType, desc string, get func(*T) any, set func(*T, string) error) Field {
+	f := Field{
+		Name:        name,
+		Type:        typ,
+		Description: desc,
+		get:         func(e any) any { return get(e.(*T)) },
+	}
+	if set != nil {
+		f.set = func(e any, v string) error { return set(e.(*T), v) }
+	}
+	return f
+}
+
+func pathField[T any](name, desc string, get func(*T) any, set func(*T, string) error) Field {
+	f := field(name, FieldString, desc, get, set)
+	f.Path = true
+	return f
+}
+
+// indexedField declares name[N], e.g. proc.args[2], for any N >= 0. It is
+// listed as name[N] and cannot be set.
+func indexedField[T any](name, desc string, get func(*T, int) any) Field {
+	return Field{
+		Name:        name + "[N]",
+		Type:        FieldString,
+		Description: desc,
+		index:       func(e any, i int) any { return get(e.(*T), i) },
+	}
+}
+
+// alias declares name as another name for a field listed before it.
+func alias(name, of string) Field {
+	return Field{Name: name, AliasOf: of}
+}
+
+type commoner interface {
+	common() *CommonEvent
+}
+
+type typeFields struct {
+	list   []Field
+	byName map[string]*Field
+}
+
+var registry = make(map[EventType]*typeFields)
+
+// registerFields adds the fields of an event type followed by the common
+// ones and resolves aliases.
+func registerFields(t EventType, fields []Field) {
+	all := append([]Field{}, fields...)
+	for _, cf := range commonFields {
+		f := cf
+		f.get = func(e any) any { return cf.get(e.(commoner).common()) }
+		f.set = func(e any, v string) error { return cf.set(e.(commoner).common(), v) }
+		all = append(all, f)
+	}
+
+	// list is allocated once so that byName can point into it.
+	tf := &typeFields{
+		list:   make([]Field, 0, len(all)),
+		byName: make(map[string]*Field, len(all)),
+	}
+	for _, f := range all {
+		if f.AliasOf != "" {
+			target, ok := tf.byName[f.AliasOf]
+			if !ok {
+				panic(fmt.Sprintf("%s: alias %s of unknown field %s", t, f.Name, f.AliasOf))
+			}
+			f.Type, f.Path, f.get, f.set, f.index = target.Type, target.Path, target.get, target.set, target.index
+			f.Description = "Alias of " + f.AliasOf + "."
+		}
+		if _, dup := tf.byName[f.Name]; dup {
+			panic(fmt.Sprintf("%s: duplicate field %s", t, f.Name))
+		}
+		tf.list = append(tf.list, f)
+		tf.byName[f.Name] = &tf.list[len(tf.list)-1]
+	}
+	registry[t] = tf
+}
+
+// lookup finds a field by name; for name[N] it also returns N.
+func (tf *typeFields) lookup(name string) (*Field, int, bool) {
+	if f, ok := tf.byName[name]; ok {
+		return f, 0, f.index == nil
+	}
+	base, rest, ok := strings.Cut(name, "[")
+	if !ok || !strings.HasSuffix(rest, "]") {
+		return nil, 0, false
+	}
+	i, err := strconv.Atoi(strings.TrimSuffix(rest, "]"))
+	if err != nil || i < 0 {
+		return nil, 0, false
+	}
+	f, ok := tf.byName[base+"[N]"]
+	return f, i, ok
+}
+
+func getField(t EventType, event any, name string) (interface{}, bool) {
+	f, i, ok := registry[t].lookup(name)
+	if !ok {
+		return nil, false
+	}
+	if f.index != nil {
+		return f.index(event, i), true
+	}
+	return f.get(event), true
+}
+
+// Fields returns the fields of an event type, type-specific ones first.
+func Fields(eventType string) ([]Field, bool) {
+	t, ok := ParseEventType(eventType)
+	if !ok {
+		return nil, false
+	}
+	return registry[t].list, true
+}
+
+// LookupField finds a field of an event type. Indexed names like
+// proc.args[2] return the proc.args[N] field.
+func LookupField(eventType, name string) (Field, bool) {
+	t, ok := ParseEventType(eventType)
+	if !ok {
+		return Field{}, false
+	}
+	f, _, ok := registry[t].lookup(name)
+	if !ok {
+		return Field{}, false
+	}
+	return *f, true
+}
+
+// EventTypeNames returns the names of all event types, sorted by type.
+func EventTypeNames() []string {
+	types := make([]EventType, 0, len(eventTypeNames))
+	for t := range eventTypeNames {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return names
+}
+
+// FieldsMarkdown documents every field of every event type.
+func FieldsMarkdown() string {
+	var b strings.Builder
+	b.WriteString("# Rule fields\n\n")
+	b.WriteString("Generated by `monitor list-fields -markdown`; do not edit.\n")
+	for _, name := range EventTypeNames() {
+		fields, _ := Fields(name)
+		fmt.Fprintf(&b, "\n## %s\n\n", name)
+		b.WriteString("| Field | Type | Description |\n")
+		b.WriteString("|-------|------|-------------|\n")
+		for _, f := range fields {
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", f.Name, f.Type, f.Description)
+		}
+	}
+	return b.String()
+}
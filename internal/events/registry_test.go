@@ -0,0 +1,125 @@
+package events
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRegistryFields(t *testing.T) {
+	for _, name := range EventTypeNames() {
+		fields, ok := Fields(name)
+		if !ok || len(fields) == 0 {
+			t.Fatalf("%s: no fields", name)
+		}
+		typ, _ := ParseEventType(name)
+		evt, _ := NewEvent(typ)
+		for _, f := range fields {
+			if f.Description == "" {
+				t.Errorf("%s %s: no description", name, f.Name)
+			}
+			if _, ok := evt.GetField(strings.Replace(f.Name, "[N]", "[0]", 1)); !ok {
+				t.Errorf("%s %s: GetField failed", name, f.Name)
+			}
+		}
+	}
+}
+
+func TestFdNumIsDescriptor(t *testing.T) {
+	failed := []EventGetter{
+		&OpenatEvent{Ret: -13},
+		&AcceptEvent{Ret: -11},
+		&MemfdEvent{Ret: -24},
+		&ConnectEvent{Fd: -1, Ret: -111},
+	}
+	for _, evt := range failed {
+		if v, _ := evt.GetField("fd.num"); v != -1 {
+			t.Errorf("%s fd.num = %v after failure, want -1", evt.GetType(), v)
+		}
+	}
+
+	if v, _ := (&AcceptEvent{Ret: 6}).GetField("fd.num"); v != 6 {
+		t.Errorf("accept fd.num = %v, want 6", v)
+	}
+	if v, _ := (&ConnectEvent{Fd: 5, Ret: -115}).GetField("fd.num"); v != 5 {
+		t.Errorf("connect fd.num = %v, want 5", v)
+	}
+}
+
+func TestRelativeNames(t *testing.T) {
+	tests := []struct {
+		evt  EventGetter
+		want string
+	}{
+		{&OpenatEvent{Ret: -2, Dfd: 3, Filename: "../x", Dir: "/srv/data"}, "/srv/x"},
+		{&OpenatEvent{Ret: 4, Filename: "x", Path: "/srv/data/x", Dir: "/ignored"}, "/srv/data/x"},
+		{&OpenatEvent{Ret: -2, Filename: "x"}, "x"},
+		{&ChmodEvent{Dfd: AtFdcwd, Filename: "bin/tool", Dir: "/home/u"}, "/home/u/bin/tool"},
+		{&ChmodEvent{Filename: "/tmp/x", Dir: "/home/u"}, "/tmp/x"},
+	}
+	for _, tc := range tests {
+		if v, _ := tc.evt.GetField("fd.name"); v != tc.want {
+			t.Errorf("%s %+v: fd.name = %v, want %s", tc.evt.GetType(), tc.evt, v, tc.want)
+		}
+	}
+}
+
+func TestIndexedArgs(t *testing.T) {
+	evt := &ExecveEvent{Argc: 3, Args: []string{"python3", "-c"}, Flags: ExecveArgsTruncated}
+	tests := map[string]any{
+		"proc.args[0]":        "python3",
+		"proc.args[1]":        "-c",
+		"proc.args[2]":        "",
+		"proc.argc":           3,
+		"proc.args_truncated": true,
+		"proc.env_truncated":  false,
+	}
+	for name, want := range tests {
+		if v, ok := evt.GetField(name); !ok || v != want {
+			t.Errorf("%s = %v, %v; want %v", name, v, ok, want)
+		}
+	}
+
+	for _, name := range []string{"proc.args[N]", "proc.args[-1]", "proc.args[x]", "proc.argv[0]", "proc.args[0"} {
+		if _, ok := evt.GetField(name); ok {
+			t.Errorf("%s: unexpected field", name)
+		}
+	}
+	if f, ok := LookupField("execve", "proc.args[12]"); !ok || f.Name != "proc.args[N]" {
+		t.Errorf("LookupField(proc.args[12]) = %+v, %v", f, ok)
+	}
+}
+
+func TestExeFields(t *testing.T) {
+	evt := &ExecveEvent{Common: CommonEvent{Uid: 1000, Gid: 1000}}
+	if v, _ := evt.GetField("proc.exe.owner_uid"); v != -1 {
+		t.Errorf("proc.exe.owner_uid without metadata = %v, want -1", v)
+	}
+
+	tests := []struct {
+		owner, group, mode uint32
+		want               bool
+	}{
+		{1000, 0, 0o755, true},
+		{1000, 1000, 0o577, false},
+		{0, 1000, 0o775, true},
+		{0, 0, 0o755, false},
+		{0, 0, 0o757, true},
+	}
+	for _, tc := range tests {
+		evt.Exe = &ExeInfo{OwnerUid: tc.owner, OwnerGid: tc.group, Mode: tc.mode}
+		if v, _ := evt.GetField("proc.exe.writable_by_user"); v != tc.want {
+			t.Errorf("owner %d:%d mode 0%o: writable_by_user = %v, want %v", tc.owner, tc.group, tc.mode, v, tc.want)
+		}
+	}
+}
+
+func TestFieldsDocUpToDate(t *testing.T) {
+	doc, err := os.ReadFile("../../docs/fields.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(doc) != FieldsMarkdown() {
+		t.Fatal("docs/fields.md is stale; regenerate it with: go run ./cmd/monitor list-fields -markdown > docs/fields.md")
+	}
+}
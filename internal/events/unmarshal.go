@@ -0,0 +1,334 @@
+package events
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+)
+
+// Record sizes without trailing C padding. Offsets below follow the struct
+// layouts in trace.c.in; the kernel writes them in host byte order. openat,
+// execve, chmod and deny records are followed by as many string bytes as
+// their length fields say.
+const (
+	commonEventSize  = 72
+	openatEventSize  = commonEventSize + 24
+	execveEventSize  = commonEventSize + 24
+	connectEventSize = commonEventSize + 14
+	acceptEventSize  = commonEventSize + 10
+	ptraceEventSize  = commonEventSize + 32
+	memfdEventSize   = commonEventSize + 8 + 128
+	chmodEventSize   = commonEventSize + 20
+	denyEventSize    = commonEventSize + 28
+)
+
+var native = binary.NativeEndian
+
+func checkSize(t EventType, data []byte, size int) error {
+	if len(data) < size {
+		return fmt.Errorf("%s record too short: need %d bytes, got %d", t, size, len(data))
+	}
+	return nil
+}
+
+func (h *EventHeader) unmarshal(data []byte) {
+	h.Type = EventType(native.Uint32(data[0:4]))
+	h.Size = native.Uint32(data[4:8])
+	h.TimestampNs = native.Uint64(data[8:16])
+}
+
+func (c *CommonEvent) unmarshal(data []byte) {
+	c.Header.unmarshal(data)
+	c.CgroupId = native.Uint64(data[16:24])
+	c.Pid = native.Uint32(data[24:28])
+	c.Ppid = native.Uint32(data[28:32])
+	c.Uid = native.Uint32(data[32:36])
+	c.Gid = native.Uint32(data[36:40])
+	copy(c.Comm[:], data[40:56])
+	copy(c.Pcomm[:], data[56:72])
+}
+
+func (e *OpenatEvent) UnmarshalBinary(data []byte) error {
+	if err := checkSize(EventOpenat, data, openatEventSize); err != nil {
+		return err
+	}
+	e.Common.unmarshal(data)
+	e.Flags = int32(native.Uint32(data[72:76]))
+	e.Dfd = int32(native.Uint32(data[76:80]))
+	e.Ret = int32(native.Uint32(data[80:84]))
+	nameLen := int(native.Uint32(data[84:88]))
+	pathLen := int(native.Uint32(data[88:92]))
+	dirLen := int(native.Uint32(data[92:96]))
+
+	if err := checkSize(EventOpenat, data, openatEventSize+nameLen+pathLen+dirLen); err != nil {
+		return err
+	}
+	tail := data[openatEventSize:]
+	e.Filename = BytesToString(tail[:nameLen])
+	tail = tail[nameLen:]
+	e.Path = BytesToString(tail[:pathLen])
+	e.Dir = BytesToString(tail[pathLen : pathLen+dirLen])
+	return nil
+}
+
+func (e *ExecveEvent) UnmarshalBinary(data []byte) error {
+	if err := checkSize(EventExecve, data, execveEventSize); err != nil {
+		return err
+	}
+	e.Common.unmarshal(data)
+	e.Ret = int32(native.Uint32(data[72:76]))
+	e.Argc = native.Uint32(data[76:80])
+	nameLen := int(native.Uint32(data[80:84]))
+	argsLen := int(native.Uint32(data[84:88]))
+	envLen := int(native.Uint32(data[88:92]))
+	e.Flags = native.Uint32(data[92:96])
+
+	if err := checkSize(EventExecve, data, execveEventSize+nameLen+argsLen+envLen); err != nil {
+		return err
+	}
+	tail := data[execveEventSize:]
+	e.Filename = BytesToString(tail[:nameLen])
+	tail = tail[nameLen:]
+	e.Args = SplitStrings(tail[:argsLen])
+	e.Env = SplitStrings(tail[argsLen : argsLen+envLen])
+	return nil
+}
+
+func (e *ConnectEvent) UnmarshalBinary(data []byte) error {
+	if err := checkSize(EventConnect, data, connectEventSize); err != nil {
+		return err
+	}
+	e.Common.unmarshal(data)
+	e.Ret = int32(native.Uint32(data[72:76]))
+	e.Fd = int32(native.Uint32(data[76:80]))
+	e.Ip = native.Uint32(data[80:84])
+	e.Port = native.Uint16(data[84:86])
+	return nil
+}
+
+func (e *AcceptEvent) UnmarshalBinary(data []byte) error {
+	if err := checkSize(EventAccept, data, acceptEventSize); err != nil {
+		return err
+	}
+	e.Common.unmarshal(data)
+	e.Ret = int32(native.Uint32(data[72:76]))
+	e.Ip = native.Uint32(data[76:80])
+	e.Port = native.Uint16(data[80:82])
+	return nil
+}
+
+func (e *PtraceEvent) UnmarshalBinary(data []byte) error {
+	if err := checkSize(EventPtrace, data, ptraceEventSize); err != nil {
+		return err
+	}
+	e.Common.unmarshal(data)
+	e.Ret = int32(native.Uint32(data[72:76]))
+	e.Request = native.Uint64(data[80:88])
+	e.TargetPid = int32(native.Uint32(data[88:92]))
+	e.Addr = native.Uint64(data[96:104])
+	return nil
+}
+
+func (e *MemfdEvent) UnmarshalBinary(data []byte) error {
+	if err := checkSize(EventMemfd, data, memfdEventSize); err != nil {
+		return err
+	}
+	e.Common.unmarshal(data)
+	e.Ret = int32(native.Uint32(data[72:76]))
+	e.Flags = native.Uint32(data[76:80])
+	copy(e.Name[:], data[80:208])
+	return nil
+}
+
+func (e *ChmodEvent) UnmarshalBinary(data []byte) error {
+	if err := checkSize(EventChmod, data, chmodEventSize); err != nil {
+		return err
+	}
+	e.Common.unmarshal(data)
+	e.Ret = int32(native.Uint32(data[72:76]))
+	e.Mode = native.Uint32(data[76:80])
+	e.Dfd = int32(native.Uint32(data[80:84]))
+	nameLen := int(native.Uint32(data[84:88]))
+	dirLen := int(native.Uint32(data[88:92]))
+
+	if err := checkSize(EventChmod, data, chmodEventSize+nameLen+dirLen); err != nil {
+		return err
+	}
+	tail := data[chmodEventSize:]
+	e.Filename = BytesToString(tail[:nameLen])
+	e.Dir = BytesToString(tail[nameLen : nameLen+dirLen])
+	return nil
+}
+
+func (e *DenyEvent) UnmarshalBinary(data []byte) error {
+	if err := checkSize(EventDeny, data, denyEventSize); err != nil {
+		return err
+	}
+	e.Common.unmarshal(data)
+	e.Hook = native.Uint32(data[72:76])
+	e.RuleId = native.Uint32(data[76:80])
+	e.Blocked = native.Uint32(data[80:84])
+	e.Ip = native.Uint32(data[84:88])
+	e.Port = native.Uint16(data[88:90])
+	e.Pad = native.Uint16(data[90:92])
+	e.TargetPid = int32(native.Uint32(data[92:96]))
+	pathLen := int(native.Uint32(data[96:100]))
+
+	if err := checkSize(EventDeny, data, denyEventSize+pathLen); err != nil {
+		return err
+	}
+	e.Path = BytesToString(data[denyEventSize : denyEventSize+pathLen])
+	return nil
+}
+
+func (c *CommonEvent) marshal(data []byte) {
+	native.PutUint32(data[0:4], uint32(c.Header.Type))
+	native.PutUint32(data[4:8], c.Header.Size)
+	native.PutUint64(data[8:16], c.Header.TimestampNs)
+	native.PutUint64(data[16:24], c.CgroupId)
+	native.PutUint32(data[24:28], c.Pid)
+	native.PutUint32(data[28:32], c.Ppid)
+	native.PutUint32(data[32:36], c.Uid)
+	native.PutUint32(data[36:40], c.Gid)
+	copy(data[40:56], c.Comm[:])
+	copy(data[56:72], c.Pcomm[:])
+}
+
+// cString encodes s NUL-terminated, or not at all if empty.
+func cString(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	return cStrings(s)
+}
+
+// cStrings encodes strings NUL-terminated, one after another.
+func cStrings(strs ...string) []byte {
+	var b []byte
+	for _, s := range strs {
+		b = append(b, s...)
+		b = append(b, 0)
+	}
+	return b
+}
+
+// MarshalBinary lays out the event as the kernel does, for tests and tools
+// that feed records to a decoder. Fixed-size events can use binary.Write.
+func (e *OpenatEvent) MarshalBinary() ([]byte, error) {
+	name, path, dir := cStrings(e.Filename), cString(e.Path), cString(e.Dir)
+	data := make([]byte, openatEventSize, openatEventSize+len(name)+len(path)+len(dir))
+	e.Common.marshal(data)
+	native.PutUint32(data[72:76], uint32(e.Flags))
+	native.PutUint32(data[76:80], uint32(e.Dfd))
+	native.PutUint32(data[80:84], uint32(e.Ret))
+	native.PutUint32(data[84:88], uint32(len(name)))
+	native.PutUint32(data[88:92], uint32(len(path)))
+	native.PutUint32(data[92:96], uint32(len(dir)))
+	return append(append(append(data, name...), path...), dir...), nil
+}
+
+func (e *ExecveEvent) MarshalBinary() ([]byte, error) {
+	name, args, env := cStrings(e.Filename), cStrings(e.Args...), cStrings(e.Env...)
+	data := make([]byte, execveEventSize, execveEventSize+len(name)+len(args)+len(env))
+	e.Common.marshal(data)
+	native.PutUint32(data[72:76], uint32(e.Ret))
+	native.PutUint32(data[76:80], e.Argc)
+	native.PutUint32(data[80:84], uint32(len(name)))
+	native.PutUint32(data[84:88], uint32(len(args)))
+	native.PutUint32(data[88:92], uint32(len(env)))
+	native.PutUint32(data[92:96], e.Flags)
+	return append(append(append(data, name...), args...), env...), nil
+}
+
+func (e *ChmodEvent) MarshalBinary() ([]byte, error) {
+	name, dir := cStrings(e.Filename), cString(e.Dir)
+	data := make([]byte, chmodEventSize, chmodEventSize+len(name)+len(dir))
+	e.Common.marshal(data)
+	native.PutUint32(data[72:76], uint32(e.Ret))
+	native.PutUint32(data[76:80], e.Mode)
+	native.PutUint32(data[80:84], uint32(e.Dfd))
+	native.PutUint32(data[84:88], uint32(len(name)))
+	native.PutUint32(data[88:92], uint32(len(dir)))
+	return append(append(data, name...), dir...), nil
+}
+
+func (e *DenyEvent) MarshalBinary() ([]byte, error) {
+	path := cString(e.Path)
+	data := make([]byte, denyEventSize, denyEventSize+len(path))
+	e.Common.marshal(data)
+	native.PutUint32(data[72:76], e.Hook)
+	native.PutUint32(data[76:80], e.RuleId)
+	native.PutUint32(data[80:84], e.Blocked)
+	native.PutUint32(data[84:88], e.Ip)
+	native.PutUint16(data[88:90], e.Port)
+	native.PutUint16(data[90:92], e.Pad)
+	native.PutUint32(data[92:96], uint32(e.TargetPid))
+	native.PutUint32(data[96:100], uint32(len(path)))
+	return append(data, path...), nil
+}
+
+// Encode lays out evt as the ring buffer record the kernel writes for it:
+// variable-length events through MarshalBinary, fixed-size ones through
+// binary.Write. The header size is set to the record length.
+func Encode(evt any) ([]byte, error) {
+	var data []byte
+	if m, ok := evt.(encoding.BinaryMarshaler); ok {
+		var err error
+		if data, err = m.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	} else {
+		var buf bytes.Buffer
+		if err := binary.Write(&buf, native, evt); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	}
+	if len(data) < commonEventSize {
+		return nil, fmt.Errorf("%T is not an event", evt)
+	}
+	native.PutUint32(data[4:8], uint32(len(data)))
+	return data, nil
+}
+
+// DecodeFunc turns a raw ring buffer record into an event.
+type DecodeFunc func(data []byte) (EventGetter, error)
+
+// Decodable is satisfied by pointers to the event structs of this package.
+type Decodable[T any] interface {
+	*T
+	EventGetter
+	UnmarshalBinary(data []byte) error
+}
+
+func Decoder[T any, P Decodable[T]]() DecodeFunc {
+	return func(data []byte) (EventGetter, error) {
+		event := P(new(T))
+		if err := event.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return event, nil
+	}
+}
+
+var eventStructs = map[EventType]func() EventGetter{
+	EventOpenat:  func() EventGetter { return &OpenatEvent{} },
+	EventExecve:  func() EventGetter { return &ExecveEvent{} },
+	EventConnect: func() EventGetter { return &ConnectEvent{} },
+	EventAccept:  func() EventGetter { return &AcceptEvent{} },
+	EventPtrace:  func() EventGetter { return &PtraceEvent{} },
+	EventMemfd:   func() EventGetter { return &MemfdEvent{} },
+	EventChmod:   func() EventGetter { return &ChmodEvent{} },
+	EventDeny:    func() EventGetter { return &DenyEvent{} },
+}
+
+// NewEvent returns an empty event struct of type t. Every struct implements
+// encoding.BinaryUnmarshaler.
+func NewEvent(t EventType) (EventGetter, bool) {
+	newEvent, ok := eventStructs[t]
+	if !ok {
+		return nil, false
+	}
+	return newEvent(), true
+}
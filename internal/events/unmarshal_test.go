@@ -0,0 +1,300 @@
+package events
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type binaryEvent interface {
+	UnmarshalBinary(data []byte) error
+}
+
+func sampleEvents() []struct {
+	name  string
+	event any
+	empty func() binaryEvent
+} {
+	common := CommonEvent{
+		Header:   EventHeader{Type: EventOpenat, Size: 216, TimestampNs: 123456789},
+		CgroupId: 9892,
+		Pid:      363847,
+		Ppid:     363846,
+		Uid:      1000,
+		Gid:      1000,
+	}
+	copy(common.Comm[:], "curl")
+	copy(common.Pcomm[:], "bash")
+
+	openat := OpenatEvent{Common: common, Flags: 577, Dfd: -100, Ret: 3, Filename: "syslog", Path: "/var/log/syslog"}
+	failed := OpenatEvent{Common: common, Dfd: 5, Ret: -2, Filename: "missing", Dir: "/srv/data"}
+
+	execve := ExecveEvent{
+		Common:   common,
+		Ret:      0,
+		Argc:     4,
+		Flags:    ExecveArgsTruncated,
+		Filename: "/usr/bin/curl",
+		Args:     []string{"curl", "-s", "", "https://exa"},
+		Env:      []string{"HOME=/root", "TERM=xterm"},
+	}
+
+	memfd := MemfdEvent{Common: common, Ret: 4, Flags: 1}
+	copy(memfd.Name[:], "payload")
+
+	chmod := ChmodEvent{Common: common, Ret: 0, Mode: 0o4755, Dfd: AtFdcwd, Filename: "x", Dir: "/tmp"}
+
+	// Longer than the 128 bytes deny records used to hold.
+	deny := DenyEvent{Common: common, Hook: DenyHookFileOpen, RuleId: 2, Blocked: 1, Path: "/srv/" + strings.Repeat("nested/", 30) + "secret"}
+	denyConnect := DenyEvent{Common: common, Hook: DenyHookSocketConnect, Ip: 0x0100007f, Port: 0x401f}
+
+	return []struct {
+		name  string
+		event any
+		empty func() binaryEvent
+	}{
+		{"openat", &openat, func() binaryEvent { return &OpenatEvent{} }},
+		{"openat failed", &failed, func() binaryEvent { return &OpenatEvent{} }},
+		{"execve", &execve, func() binaryEvent { return &ExecveEvent{} }},
+		{"connect", &ConnectEvent{Common: common, Ret: -115, Fd: 5, Ip: 0x0100007f, Port: 0x401f}, func() binaryEvent { return &ConnectEvent{} }},
+		{"accept", &AcceptEvent{Common: common, Ret: 6, Ip: 0x0100007f, Port: 0x401f}, func() binaryEvent { return &AcceptEvent{} }},
+		{"ptrace", &PtraceEvent{Common: common, Ret: 0, Request: 16, TargetPid: 42, Addr: 0xdeadbeef}, func() binaryEvent { return &PtraceEvent{} }},
+		{"memfd_create", &memfd, func() binaryEvent { return &MemfdEvent{} }},
+		{"chmod", &chmod, func() binaryEvent { return &ChmodEvent{} }},
+		{"deny", &deny, func() binaryEvent { return &DenyEvent{} }},
+		{"deny connect", &denyConnect, func() binaryEvent { return &DenyEvent{} }},
+	}
+}
+
+func encode(tb testing.TB, event any) []byte {
+	data, err := Encode(event)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+func commonOf(event any) *CommonEvent {
+	return reflect.ValueOf(event).Elem().FieldByName("Common").Addr().Interface().(*CommonEvent)
+}
+
+func TestUnmarshalBinaryRoundTrip(t *testing.T) {
+	for _, tc := range sampleEvents() {
+		t.Run(tc.name, func(t *testing.T) {
+			data := encode(t, tc.event)
+
+			got := tc.empty()
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			if size := commonOf(got).Header.Size; size != uint32(len(data)) {
+				t.Fatalf("header size %d, want %d", size, len(data))
+			}
+			commonOf(got).Header.Size = commonOf(tc.event).Header.Size
+			if !reflect.DeepEqual(got, tc.event) {
+				t.Fatalf("decoded %+v, want %+v", got, tc.event)
+			}
+
+			if err := tc.empty().UnmarshalBinary(data[:len(data)-1]); err == nil {
+				t.Fatal("expected error for truncated record")
+			}
+		})
+	}
+}
+
+// rawRecord builds a record field by field as trace.c.in lays it out: the
+// common fields that commonEvent expects, then tail.
+func rawRecord(typ EventType, tail ...[]byte) []byte {
+	data := native.AppendUint32(nil, uint32(typ))
+	data = native.AppendUint32(data, 0) // size, set below
+	data = native.AppendUint64(data, 123456789)
+	data = native.AppendUint64(data, 9892)
+	data = native.AppendUint32(data, 363847)
+	data = native.AppendUint32(data, 363846)
+	data = native.AppendUint32(data, 1000)
+	data = native.AppendUint32(data, 1000)
+	data = append(data, "curl\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"...)
+	data = append(data, "bash\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"...)
+	for _, b := range tail {
+		data = append(data, b...)
+	}
+	native.PutUint32(data[4:8], uint32(len(data)))
+	return data
+}
+
+func u32(vs ...uint32) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = native.AppendUint32(b, v)
+	}
+	return b
+}
+
+func commonEvent(typ EventType, size int) CommonEvent {
+	c := CommonEvent{
+		Header:   EventHeader{Type: typ, Size: uint32(size), TimestampNs: 123456789},
+		CgroupId: 9892,
+		Pid:      363847,
+		Ppid:     363846,
+		Uid:      1000,
+		Gid:      1000,
+	}
+	copy(c.Comm[:], "curl")
+	copy(c.Pcomm[:], "bash")
+	return c
+}
+
+// The variable-length records are decoded from bytes built by hand, not by
+// MarshalBinary, so that a shared mistake cannot make the round trip pass.
+func TestUnmarshalBinaryRawRecords(t *testing.T) {
+	const atFdcwd = 0xffffff9c // -100
+
+	openat := rawRecord(EventOpenat,
+		u32(577, atFdcwd, 3, 7, 16, 0), // flags, dfd, ret, name_len, path_len, dir_len
+		[]byte("syslog\x00/var/log/syslog\x00"))
+	failed := rawRecord(EventOpenat,
+		u32(0, 5, 0xfffffffe, 8, 0, 10),
+		[]byte("missing\x00/srv/data\x00"))
+	execve := rawRecord(EventExecve,
+		u32(0, 2, 14, 8, 11, ExecveArgsTruncated), // ret, argc, name_len, args_len, env_len, flags
+		[]byte("/usr/bin/curl\x00curl\x00-s\x00HOME=/root\x00"))
+	deny := rawRecord(EventDeny,
+		u32(DenyHookBprmCheck, 1, 1, 0, 0, 0, 10), // hook, rule_id, blocked, ip, port and _pad, target_pid, path_len
+		[]byte("/tmp/x.sh\x00"))
+	chmod := rawRecord(EventChmod,
+		u32(0, 0o4755, atFdcwd, 2, 5), // ret, mode, dfd, name_len, dir_len
+		[]byte("x\x00/tmp\x00"))
+
+	tests := []struct {
+		name  string
+		raw   []byte
+		empty func() binaryEvent
+		want  any
+	}{
+		{"openat", openat, func() binaryEvent { return &OpenatEvent{} }, &OpenatEvent{
+			Common: commonEvent(EventOpenat, len(openat)), Flags: 577, Dfd: -100, Ret: 3,
+			Filename: "syslog", Path: "/var/log/syslog",
+		}},
+		{"openat failed", failed, func() binaryEvent { return &OpenatEvent{} }, &OpenatEvent{
+			Common: commonEvent(EventOpenat, len(failed)), Dfd: 5, Ret: -2,
+			Filename: "missing", Dir: "/srv/data",
+		}},
+		{"execve", execve, func() binaryEvent { return &ExecveEvent{} }, &ExecveEvent{
+			Common: commonEvent(EventExecve, len(execve)), Argc: 2, Flags: ExecveArgsTruncated,
+			Filename: "/usr/bin/curl", Args: []string{"curl", "-s"}, Env: []string{"HOME=/root"},
+		}},
+		{"chmod", chmod, func() binaryEvent { return &ChmodEvent{} }, &ChmodEvent{
+			Common: commonEvent(EventChmod, len(chmod)), Mode: 0o4755, Dfd: AtFdcwd,
+			Filename: "x", Dir: "/tmp",
+		}},
+		{"deny", deny, func() binaryEvent { return &DenyEvent{} }, &DenyEvent{
+			Common: commonEvent(EventDeny, len(deny)), Hook: DenyHookBprmCheck, RuleId: 1, Blocked: 1,
+			Path: "/tmp/x.sh",
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.empty()
+			if err := got.UnmarshalBinary(tc.raw); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("decoded %+v, want %+v", got, tc.want)
+			}
+
+			if err := tc.empty().UnmarshalBinary(tc.raw[:len(tc.raw)-1]); err == nil {
+				t.Fatal("expected error for truncated strings")
+			}
+
+			data, err := Encode(tc.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, tc.raw) {
+				t.Fatalf("Encode gave\n%x, want\n%x", data, tc.raw)
+			}
+		})
+	}
+}
+
+// clearPadding zeroes the Pad* fields, which UnmarshalBinary may skip.
+func clearPadding(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		if strings.HasPrefix(v.Type().Field(i).Name, "Pad") {
+			v.Field(i).SetZero()
+		}
+	}
+}
+
+// The fixed-size types mirror the C structs field for field, so binary.Read
+// decodes their records by the Go layout; UnmarshalBinary must agree with it.
+func TestUnmarshalBinaryMatchesBinaryRead(t *testing.T) {
+	for _, tc := range sampleEvents() {
+		if _, ok := tc.event.(encoding.BinaryMarshaler); ok {
+			// binary.Read cannot decode the variable-length strings.
+			continue
+		}
+		t.Run(tc.name, func(t *testing.T) {
+			// Distinct bytes everywhere, so a shifted offset cannot go unseen.
+			data := make([]byte, binary.Size(tc.event))
+			for i := range data {
+				data[i] = byte(i*7 + 1)
+			}
+
+			want := tc.empty()
+			if err := binary.Read(bytes.NewReader(data), binary.NativeEndian, want); err != nil {
+				t.Fatal(err)
+			}
+			got := tc.empty()
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			clearPadding(reflect.ValueOf(want).Elem())
+			clearPadding(reflect.ValueOf(got).Elem())
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("UnmarshalBinary %+v, binary.Read %+v", got, want)
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshalBinary(b *testing.B) {
+	for _, tc := range sampleEvents() {
+		b.Run(tc.name, func(b *testing.B) {
+			data := encode(b, tc.event)
+			event := tc.empty()
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for b.Loop() {
+				if err := event.UnmarshalBinary(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "events/s")
+		})
+	}
+}
+
+func BenchmarkBinaryRead(b *testing.B) {
+	for _, tc := range sampleEvents() {
+		if _, ok := tc.event.(encoding.BinaryMarshaler); ok {
+			// binary.Read cannot decode the variable-length strings.
+			continue
+		}
+		b.Run(tc.name, func(b *testing.B) {
+			data := encode(b, tc.event)
+			event := tc.empty()
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for b.Loop() {
+				if err := binary.Read(bytes.NewReader(data), binary.NativeEndian, event); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "events/s")
+		})
+	}
+}
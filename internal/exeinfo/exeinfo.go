@@ -0,0 +1,337 @@
+// Package exeinfo hashes executed files and reads their ownership and ELF
+// metadata for execve events.
+package exeinfo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"debug/elf"
+	"diploma/internal/events"
+	"diploma/internal/metrics"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// headSize is how much of a file is searched for a #! line or a UPX marker.
+const headSize = 4096
+
+// Config controls executable metadata. Files not seen before are read by
+// Workers goroutines, so hashing a large new binary does not hold up other
+// events; MaxFileSize bounds the time one file takes.
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Larger files get ownership and ELF metadata but no hash.
+	MaxFileSize int64 `yaml:"max_file_size"`
+	// CacheSize caps the files remembered; the cache is cleared when full.
+	CacheSize int `yaml:"cache_size"`
+	Workers   int `yaml:"workers"`
+	// QueueSize caps the files waiting for a worker. Events of files that
+	// do not fit are handled without metadata.
+	QueueSize int `yaml:"queue_size"`
+}
+
+// key identifies one version of a file: a rewrite in place changes mtime, a
+// replacement changes the inode.
+type key struct {
+	dev, ino uint64
+	mtime    int64
+	size     int64
+}
+
+// content is what depends only on the file's bytes and is cached.
+type content struct {
+	sha256      string
+	elf         bool
+	static      bool
+	interpreter string
+	stripped    bool
+	packed      bool
+}
+
+// job is an opened file waiting for a worker.
+type job struct {
+	f    *os.File
+	st   *syscall.Stat_t
+	done func(*events.ExeInfo)
+}
+
+type Cache struct {
+	cfg Config
+
+	mu    sync.Mutex
+	files map[key]*content
+	// closed is set by Close; jobs is closed with it under mu.
+	closed bool
+
+	jobs chan job
+	wg   sync.WaitGroup
+}
+
+// New starts the workers; Close stops them.
+func New(cfg Config) *Cache {
+	c := &Cache{
+		cfg:   cfg,
+		files: make(map[key]*content),
+		jobs:  make(chan job, cfg.QueueSize),
+	}
+	for i := 0; i < max(cfg.Workers, 1); i++ {
+		c.wg.Add(1)
+		go c.work()
+	}
+	return c
+}
+
+// Close waits for the queued files and stops the workers. Afterwards
+// Describe reports files not in the cache as unreadable.
+func (c *Cache) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	close(c.jobs)
+	c.mu.Unlock()
+	c.wg.Wait()
+}
+
+func (c *Cache) work() {
+	defer c.wg.Done()
+	for j := range c.jobs {
+		info, err := c.describe(j.f, j.st)
+		j.f.Close()
+		if err != nil {
+			info = nil
+		}
+		j.done(info)
+	}
+}
+
+// Describe calls done with a description of the file a process executed, or
+// with nil if it cannot be read; pid and path are as for Lookup. A known file
+// version is described right away, on the caller's goroutine; other files are
+// read by a worker, which then calls done.
+func (c *Cache) Describe(pid uint32, path string, done func(*events.ExeInfo)) {
+	f, st, err := openStat(pid, path)
+	if err != nil {
+		metrics.ExeInfoLookups.Inc("error")
+		done(nil)
+		return
+	}
+
+	c.mu.Lock()
+	cached := c.files[keyOf(st)]
+	queued := false
+	if cached == nil && !c.closed {
+		select {
+		case c.jobs <- job{f: f, st: st, done: done}:
+			queued = true
+		default:
+		}
+	}
+	closed := c.closed
+	c.mu.Unlock()
+
+	switch {
+	case queued:
+	case cached != nil:
+		f.Close()
+		metrics.ExeInfoLookups.Inc("cached")
+		done(cached.info(st))
+	case closed:
+		f.Close()
+		metrics.ExeInfoLookups.Inc("error")
+		done(nil)
+	default:
+		f.Close()
+		metrics.ExeInfoLookups.Inc("queue_full")
+		done(nil)
+	}
+}
+
+// Lookup describes the file a process executed, reading it on the caller's
+// goroutine. If pid is not 0 the file is read through /proc/<pid>/exe, which
+// still works after path was deleted, provided it is the file at path, or
+// else at path in the process's root; see open. Ownership and mode are read on every call, the hash and ELF metadata
+// only for a file version not seen before.
+func (c *Cache) Lookup(pid uint32, path string) (*events.ExeInfo, error) {
+	f, st, err := openStat(pid, path)
+	if err != nil {
+		metrics.ExeInfoLookups.Inc("error")
+		return nil, err
+	}
+	defer f.Close()
+	return c.describe(f, st)
+}
+
+func (c *Cache) describe(f *os.File, st *syscall.Stat_t) (*events.ExeInfo, error) {
+	k := keyOf(st)
+	c.mu.Lock()
+	cached, ok := c.files[k]
+	c.mu.Unlock()
+	if ok {
+		metrics.ExeInfoLookups.Inc("cached")
+		return cached.info(st), nil
+	}
+
+	cached, err := c.read(f, st.Size)
+	if err != nil {
+		metrics.ExeInfoLookups.Inc("error")
+		return nil, err
+	}
+	metrics.ExeInfoLookups.Inc("computed")
+
+	c.mu.Lock()
+	if len(c.files) >= c.cfg.CacheSize {
+		c.files = make(map[key]*content)
+	}
+	c.files[k] = cached
+	c.mu.Unlock()
+	return cached.info(st), nil
+}
+
+func keyOf(st *syscall.Stat_t) key {
+	return key{
+		dev:   uint64(st.Dev),
+		ino:   st.Ino,
+		mtime: st.Mtim.Nano(),
+		size:  st.Size,
+	}
+}
+
+func (c *content) info(st *syscall.Stat_t) *events.ExeInfo {
+	return &events.ExeInfo{
+		SHA256:      c.sha256,
+		OwnerUid:    st.Uid,
+		OwnerGid:    st.Gid,
+		Mode:        uint32(st.Mode) & 0o7777,
+		ELF:         c.elf,
+		Static:      c.static,
+		Interpreter: c.interpreter,
+		Stripped:    c.stripped,
+		Packed:      c.packed,
+	}
+}
+
+// openStat opens the executed file and checks that it is a regular file.
+func openStat(pid uint32, path string) (*os.File, *syscall.Stat_t, error) {
+	f, err := open(pid, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || !fi.Mode().IsRegular() {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: not a regular file", f.Name())
+	}
+	return f, st, nil
+}
+
+// open returns /proc/<pid>/exe if it is the file at path, or was before path
+// was deleted, and otherwise path itself. By the time the event is handled
+// the process may have executed something else, and for a script exe is its
+// interpreter. path is resolved in the process's mount namespace through
+// /proc/<pid>/root, so a file of the same name on the host is never read for
+// a container; once the process is gone the file cannot be opened. A pid of
+// 0 opens path as seen by the monitor.
+func open(pid uint32, path string) (*os.File, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("cannot open %q: path not absolute", path)
+	}
+	if pid == 0 {
+		return os.Open(path)
+	}
+
+	rooted := fmt.Sprintf("/proc/%d/root%s", pid, path)
+	if f, err := openProcExe(pid, path, rooted); err == nil {
+		return f, nil
+	}
+	return os.Open(rooted)
+}
+
+func openProcExe(pid uint32, path, rooted string) (*os.File, error) {
+	exe := fmt.Sprintf("/proc/%d/exe", pid)
+	f, err := os.Open(exe)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err == nil {
+		if pathInfo, statErr := os.Stat(rooted); statErr == nil {
+			if os.SameFile(fi, pathInfo) {
+				return f, nil
+			}
+		} else if target, _ := os.Readlink(exe); target == path+" (deleted)" {
+			return f, nil
+		}
+		err = fmt.Errorf("%s is not %s", exe, path)
+	}
+	f.Close()
+	return nil, err
+}
+
+func (c *Cache) read(f *os.File, size int64) (*content, error) {
+	var info content
+
+	if size <= c.cfg.MaxFileSize {
+		h := sha256.New()
+		if _, err := io.Copy(h, io.NewSectionReader(f, 0, size)); err != nil {
+			return nil, fmt.Errorf("hash %s: %w", f.Name(), err)
+		}
+		info.sha256 = hex.EncodeToString(h.Sum(nil))
+	}
+
+	head := make([]byte, headSize)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read %s: %w", f.Name(), err)
+	}
+	head = head[:n]
+
+	if bytes.HasPrefix(head, []byte("#!")) {
+		info.interpreter = shebang(head)
+		return &info, nil
+	}
+
+	ef, err := elf.NewFile(f)
+	if err != nil {
+		// Not an ELF file, or a damaged one: only the hash is known.
+		return &info, nil
+	}
+	info.elf = true
+	info.static = true
+	for _, prog := range ef.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		info.static = false
+		interp, err := io.ReadAll(io.LimitReader(prog.Open(), headSize))
+		if err == nil {
+			info.interpreter = events.BytesToString(interp)
+		}
+	}
+	info.stripped = ef.Section(".symtab") == nil
+	// UPX and similar packers drop the section headers of the packed file.
+	info.packed = len(ef.Sections) == 0 || bytes.Contains(head, []byte("UPX!"))
+	return &info, nil
+}
+
+// shebang returns the interpreter named by a #! line.
+func shebang(head []byte) string {
+	line, _, _ := bytes.Cut(head[2:], []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
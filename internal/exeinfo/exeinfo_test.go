@@ -0,0 +1,134 @@
+package exeinfo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"diploma/internal/events"
+)
+
+func TestLookupExecutable(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+
+	c := New(Config{MaxFileSize: 1 << 30, CacheSize: 16})
+	info, err := c.Lookup(uint32(os.Getpid()), exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("SHA256 = %s, want %x", info.SHA256, sum)
+	}
+	if !info.ELF || info.OwnerUid != uint32(os.Getuid()) {
+		t.Errorf("info = %+v, want an ELF file owned by %d", info, os.Getuid())
+	}
+
+	small := New(Config{MaxFileSize: 1024, CacheSize: 16})
+	if info, err := small.Lookup(0, exe); err != nil || info.SHA256 != "" || !info.ELF {
+		t.Errorf("over max_file_size: %+v, %v; want ELF metadata without a hash", info, err)
+	}
+}
+
+func TestLookupScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh -e\necho one\n"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(Config{MaxFileSize: 1 << 20, CacheSize: 16})
+	// Our /proc/self/exe is not the script, so the path is read instead.
+	first, err := c.Lookup(uint32(os.Getpid()), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ELF || first.Interpreter != "/bin/sh" || first.Mode != 0o750 {
+		t.Errorf("script info = %+v", first)
+	}
+
+	if err := os.WriteFile(path, []byte("#!/bin/sh -e\necho two\n"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the rewrite is seen even on a coarse mtime clock.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Lookup(0, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.SHA256 == first.SHA256 {
+		t.Error("hash not recomputed after the file changed")
+	}
+
+	if _, err := c.Lookup(0, "run.sh"); err == nil {
+		t.Error("relative path: expected an error")
+	}
+	// The path may name another file in the root of a process that is gone.
+	if _, err := c.Lookup(1<<30, path); err == nil {
+		t.Error("process gone: expected an error, not the file at the path")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(Config{MaxFileSize: 1 << 30, CacheSize: 16, Workers: 1, QueueSize: 1})
+	defer c.Close()
+
+	got := make(chan *events.ExeInfo, 1)
+	done := func(info *events.ExeInfo) { got <- info }
+
+	c.Describe(0, exe, done)
+	first := <-got
+	if first == nil || first.SHA256 == "" {
+		t.Fatalf("first describe = %+v, want a hashed file", first)
+	}
+	// The file is cached now and described on this goroutine.
+	c.Describe(0, exe, done)
+	select {
+	case second := <-got:
+		if second.SHA256 != first.SHA256 {
+			t.Errorf("cached SHA256 = %s, want %s", second.SHA256, first.SHA256)
+		}
+	default:
+		t.Error("cached file was not described right away")
+	}
+
+	c.Describe(0, filepath.Join(t.TempDir(), "missing"), done)
+	if info := <-got; info != nil {
+		t.Errorf("missing file: %+v, want nil", info)
+	}
+}
+
+func TestDescribeAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(Config{MaxFileSize: 1 << 20, CacheSize: 16, Workers: 1, QueueSize: 1})
+	c.Close()
+	c.Close()
+
+	var got *events.ExeInfo
+	called := false
+	c.Describe(0, path, func(info *events.ExeInfo) { got, called = info, true })
+	if !called || got != nil {
+		t.Errorf("Describe after Close: called %v with %+v, want nil right away", called, got)
+	}
+}
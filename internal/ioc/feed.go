@@ -0,0 +1,255 @@
+// Package ioc loads indicator of compromise feeds from local files and
+// answers membership queries for the in_ioc rule operator.
+package ioc
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Feed is one loaded feed. Every lookup is a fixed number of map probes: one
+// per distinct prefix length for addresses, two for file names.
+type Feed struct {
+	nets   map[netip.Prefix]struct{}
+	bits4  []int
+	bits6  []int
+	hashes map[string]struct{}
+	names  map[string]struct{}
+	// Skipped counts entries that are not an address, network, SHA-256 or
+	// file name, e.g. STIX patterns on other objects.
+	Skipped int
+}
+
+func newFeed() *Feed {
+	return &Feed{
+		nets:   make(map[netip.Prefix]struct{}),
+		hashes: make(map[string]struct{}),
+		names:  make(map[string]struct{}),
+	}
+}
+
+// Len returns the number of indicators in the feed.
+func (f *Feed) Len() int {
+	return len(f.nets) + len(f.hashes) + len(f.names)
+}
+
+// Contains reports whether value, a field value as a string, is listed: an
+// IP address within a listed address or network, a SHA-256 hash, or a path
+// whose full name or base name is listed.
+func (f *Feed) Contains(value string) bool {
+	if addr, err := netip.ParseAddr(value); err == nil {
+		addr = addr.Unmap()
+		bits := f.bits6
+		if addr.Is4() {
+			bits = f.bits4
+		}
+		for _, n := range bits {
+			p, _ := addr.Prefix(n)
+			if _, ok := f.nets[p]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	if isSHA256(value) {
+		_, ok := f.hashes[strings.ToLower(value)]
+		return ok
+	}
+	if _, ok := f.names[value]; ok {
+		return true
+	}
+	_, ok := f.names[filepath.Base(value)]
+	return ok
+}
+
+// Indicator kinds, as named in the optional type column of a CSV feed.
+var kinds = map[string]string{
+	"ip": "net", "ipv4": "net", "ipv6": "net", "cidr": "net",
+	"sha256": "hash", "sha-256": "hash", "hash": "hash",
+	"filename": "name", "file": "name", "name": "name", "path": "name",
+}
+
+// add records value as an indicator of kind, or of the kind its syntax
+// suggests if kind is empty.
+func (f *Feed) add(value, kind string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	if kind == "" {
+		switch {
+		case isSHA256(value):
+			kind = "hash"
+		case isNet(value):
+			kind = "net"
+		default:
+			kind = "name"
+		}
+	}
+
+	switch kind {
+	case "net":
+		p, err := parseNet(value)
+		if err != nil {
+			return err
+		}
+		f.nets[p] = struct{}{}
+		if p.Addr().Is4() {
+			f.bits4 = addBits(f.bits4, p.Bits())
+		} else {
+			f.bits6 = addBits(f.bits6, p.Bits())
+		}
+	case "hash":
+		if !isSHA256(value) {
+			return fmt.Errorf("not a SHA-256 hash: %q", value)
+		}
+		f.hashes[strings.ToLower(value)] = struct{}{}
+	case "name":
+		f.names[value] = struct{}{}
+	}
+	return nil
+}
+
+// addBits keeps the prefix lengths present, longest first.
+func addBits(bits []int, n int) []int {
+	if slices.Contains(bits, n) {
+		return bits
+	}
+	bits = append(bits, n)
+	slices.SortFunc(bits, func(a, b int) int { return b - a })
+	return bits
+}
+
+func isNet(value string) bool {
+	_, err := parseNet(value)
+	return err == nil
+}
+
+// parseNet parses an address or a network, masking host bits.
+func parseNet(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		p, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if p.Addr().Is4In6() {
+			p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func isSHA256(value string) bool {
+	if len(value) != 64 {
+		return false
+	}
+	for _, c := range value {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCSV reads one indicator per row, optionally followed by its type
+// (ip, cidr, sha256, filename, ...) and any other columns, which are ignored.
+// Lines starting with # and a header row naming the first column
+// "indicator" or "value" are skipped.
+func parseCSV(r io.Reader) (*Feed, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	f := newFeed()
+	for first := true; ; first = false {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return f, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		head := strings.ToLower(strings.TrimSpace(row[0]))
+		if first && (head == "indicator" || head == "value") {
+			continue
+		}
+
+		var kind string
+		if len(row) > 1 && strings.TrimSpace(row[1]) != "" {
+			var ok bool
+			if kind, ok = kinds[strings.ToLower(strings.TrimSpace(row[1]))]; !ok {
+				line, _ := cr.FieldPos(1)
+				return nil, fmt.Errorf("line %d: unknown indicator type %q", line, row[1])
+			}
+		}
+		if err := f.add(row[0], kind); err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+}
+
+// stixComparison matches the comparisons a STIX-lite pattern may contain;
+// anything else in the pattern, such as AND, OR and brackets, is ignored.
+var stixComparison = regexp.MustCompile(
+	`(ipv4-addr:value|ipv6-addr:value|file:hashes\.'SHA-256'|file:hashes\.SHA256|file:name)\s*=\s*'((?:[^'\\]|\\.)*)'`)
+
+var stixKinds = map[string]string{
+	"ipv4-addr:value":       "net",
+	"ipv6-addr:value":       "net",
+	"file:hashes.'SHA-256'": "hash",
+	"file:hashes.SHA256":    "hash",
+	"file:name":             "name",
+}
+
+type stixBundle struct {
+	Objects []struct {
+		Type    string `json:"type"`
+		Pattern string `json:"pattern"`
+		Revoked bool   `json:"revoked"`
+	} `json:"objects"`
+}
+
+// parseSTIX reads the indicators of a STIX 2.1 bundle whose patterns are
+// equality comparisons on addresses, SHA-256 hashes and file names, e.g.
+// "[ipv4-addr:value = '203.0.113.0/24']". Revoked indicators are skipped.
+func parseSTIX(r io.Reader) (*Feed, error) {
+	var bundle stixBundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return nil, err
+	}
+
+	f := newFeed()
+	for i, obj := range bundle.Objects {
+		if obj.Type != "indicator" || obj.Revoked {
+			continue
+		}
+		matches := stixComparison.FindAllStringSubmatch(obj.Pattern, -1)
+		if len(matches) == 0 {
+			f.Skipped++
+			continue
+		}
+		for _, m := range matches {
+			value := strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(m[2])
+			if err := f.add(value, stixKinds[m[1]]); err != nil {
+				return nil, fmt.Errorf("object %d: %v", i, err)
+			}
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,129 @@
+package ioc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const hash = "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08"
+
+func TestParseCSV(t *testing.T) {
+	feed, err := parseCSV(strings.NewReader(`indicator,type,comment
+# scanners
+203.0.113.7
+198.51.100.0/24,cidr,"hosting, bulletproof"
+2001:db8::/32
+` + hash + `
+mimikatz
+/tmp/.x/miner,filename
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Len() != 6 {
+		t.Errorf("Len = %d, want 6", feed.Len())
+	}
+
+	tests := map[string]bool{
+		"203.0.113.7":         true,
+		"203.0.113.8":         false,
+		"198.51.100.200":      true,
+		"::ffff:198.51.100.1": true,
+		"198.51.101.1":        false,
+		"2001:db8::1":         true,
+		strings.ToLower(hash): true,
+		"/usr/local/mimikatz": true,
+		"/tmp/.x/miner":       true,
+		"/var/tmp/miner":      false,
+		"mimikatz.exe":        false,
+		"indicator":           false,
+	}
+	for value, want := range tests {
+		if got := feed.Contains(value); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", value, got, want)
+		}
+	}
+
+	if _, err := parseCSV(strings.NewReader("1.2.3.4,domain\n")); err == nil {
+		t.Error("unknown type: expected an error")
+	}
+	if _, err := parseCSV(strings.NewReader("1.2.3.4/40,cidr\n")); err == nil {
+		t.Error("bad network: expected an error")
+	}
+}
+
+func TestParseSTIX(t *testing.T) {
+	feed, err := parseSTIX(strings.NewReader(`{
+  "type": "bundle",
+  "objects": [
+    {"type": "identity", "name": "feed"},
+    {"type": "indicator", "pattern": "[ipv4-addr:value = '192.0.2.0/28'] OR [ipv4-addr:value = '192.0.2.99']"},
+    {"type": "indicator", "pattern": "[file:hashes.'SHA-256' = '` + hash + `' AND file:name = 'it\\'s']"},
+    {"type": "indicator", "pattern": "[domain-name:value = 'evil.example']"},
+    {"type": "indicator", "pattern": "[ipv4-addr:value = '192.0.2.200']", "revoked": true}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Len() != 4 || feed.Skipped != 1 {
+		t.Errorf("Len = %d, Skipped = %d; want 4, 1", feed.Len(), feed.Skipped)
+	}
+	for value, want := range map[string]bool{
+		"192.0.2.15":   true,
+		"192.0.2.16":   false,
+		"192.0.2.99":   true,
+		"192.0.2.200":  false,
+		hash:           true,
+		"/home/u/it's": true,
+	} {
+		if got := feed.Contains(value); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ips.csv")
+	write := func(data string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	write("10.0.0.1\n", now)
+
+	s := NewStore()
+	if err := s.Load(Config{Feeds: []FeedConfig{{Name: "ips", Path: path}}}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Contains("ips", "10.0.0.1") || s.Contains("other", "10.0.0.1") {
+		t.Fatal("lookup after load")
+	}
+
+	write("10.0.0.2\n", now.Add(time.Minute))
+	s.Reload()
+	if s.Contains("ips", "10.0.0.1") || !s.Contains("ips", "10.0.0.2") {
+		t.Error("changed feed not reloaded")
+	}
+
+	write("10.0.0.3,bogus\n", now.Add(2*time.Minute))
+	s.Reload()
+	if !s.Contains("ips", "10.0.0.2") {
+		t.Error("feed that failed to reload lost its contents")
+	}
+
+	if err := s.Load(Config{Feeds: []FeedConfig{{Name: "ips", Path: path + ".missing"}}}); err == nil {
+		t.Error("missing file: expected an error")
+	}
+	if !s.Contains("ips", "10.0.0.2") {
+		t.Error("failed Load changed the store")
+	}
+}
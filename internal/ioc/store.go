@@ -0,0 +1,186 @@
+package ioc
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatSTIX = "stix"
+)
+
+// FeedConfig names a feed file for rules to refer to as in_ioc:<name>.
+type FeedConfig struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+	// Format is csv or stix; by default .json files are stix, others csv.
+	Format string `yaml:"format"`
+}
+
+// Config lists the feeds. Files are checked for changes every
+// ReloadInterval and reloaded; 0 disables reloading.
+type Config struct {
+	Feeds          []FeedConfig  `yaml:"feeds"`
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+func (c *FeedConfig) format() string {
+	if c.Format != "" {
+		return c.Format
+	}
+	if strings.EqualFold(filepath.Ext(c.Path), ".json") {
+		return FormatSTIX
+	}
+	return FormatCSV
+}
+
+// stamp identifies a version of a feed file.
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+type Store struct {
+	mu     sync.RWMutex
+	feeds  map[string]*Feed
+	cfgs   []FeedConfig
+	stamps map[string]stamp
+}
+
+// Default is the store the in_ioc operator consults.
+var Default = NewStore()
+
+func NewStore() *Store {
+	return &Store{feeds: make(map[string]*Feed), stamps: make(map[string]stamp)}
+}
+
+// Load replaces the store's feeds with the configured ones. On error the
+// store is left unchanged.
+func (s *Store) Load(cfg Config) error {
+	feeds := make(map[string]*Feed)
+	stamps := make(map[string]stamp)
+	for _, fc := range cfg.Feeds {
+		if fc.Name == "" {
+			return fmt.Errorf("feed %s: no name", fc.Path)
+		}
+		if _, dup := feeds[fc.Name]; dup {
+			return fmt.Errorf("feed %s: duplicate name", fc.Name)
+		}
+		feed, st, err := loadFeed(fc)
+		if err != nil {
+			return err
+		}
+		feeds[fc.Name] = feed
+		stamps[fc.Name] = st
+	}
+
+	s.mu.Lock()
+	s.feeds = feeds
+	s.cfgs = cfg.Feeds
+	s.stamps = stamps
+	s.mu.Unlock()
+	return nil
+}
+
+func loadFeed(fc FeedConfig) (*Feed, stamp, error) {
+	file, err := os.Open(fc.Path)
+	if err != nil {
+		return nil, stamp{}, fmt.Errorf("feed %s: %w", fc.Name, err)
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, stamp{}, fmt.Errorf("feed %s: %w", fc.Name, err)
+	}
+
+	var feed *Feed
+	switch fc.format() {
+	case FormatCSV:
+		feed, err = parseCSV(file)
+	case FormatSTIX:
+		feed, err = parseSTIX(file)
+	default:
+		return nil, stamp{}, fmt.Errorf("feed %s: unknown format %q", fc.Name, fc.Format)
+	}
+	if err != nil {
+		return nil, stamp{}, fmt.Errorf("feed %s: %s: %v", fc.Name, fc.Path, err)
+	}
+	return feed, stamp{fi.ModTime(), fi.Size()}, nil
+}
+
+// Feed returns a loaded feed.
+func (s *Store) Feed(name string) (*Feed, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	feed, ok := s.feeds[name]
+	return feed, ok
+}
+
+// Contains reports whether the named feed lists value; see Feed.Contains.
+// A feed that is not loaded contains nothing.
+func (s *Store) Contains(name, value string) bool {
+	feed, ok := s.Feed(name)
+	return ok && feed.Contains(value)
+}
+
+// Reload re-reads the feed files that changed since they were loaded. A
+// feed that fails to load keeps its previous contents.
+func (s *Store) Reload() {
+	s.mu.RLock()
+	cfgs := s.cfgs
+	s.mu.RUnlock()
+
+	for _, fc := range cfgs {
+		fi, err := os.Stat(fc.Path)
+		if err != nil {
+			log.Printf("IOC feed %s: %v", fc.Name, err)
+			continue
+		}
+		s.mu.RLock()
+		old := s.stamps[fc.Name]
+		s.mu.RUnlock()
+		if fi.ModTime().Equal(old.modTime) && fi.Size() == old.size {
+			continue
+		}
+
+		feed, st, err := loadFeed(fc)
+		if err != nil {
+			log.Printf("IOC feed reload failed, keeping the previous version: %v", err)
+			continue
+		}
+		s.mu.Lock()
+		s.feeds[fc.Name] = feed
+		s.stamps[fc.Name] = st
+		s.mu.Unlock()
+		log.Printf("IOC feed %s reloaded: %d indicators", fc.Name, feed.Len())
+	}
+}
+
+// Watch calls Reload every interval until the returned function is called.
+func (s *Store) Watch(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				s.Reload()
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
@@ -0,0 +1,136 @@
+package loader
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/features"
+)
+
+// Backend selects how syscalls are hooked.
+type Backend string
+
+const (
+	// BackendAuto picks fentry when the kernel supports it, tracepoints otherwise.
+	BackendAuto Backend = "auto"
+	// BackendFentry uses fexit on the __x64_sys_* wrappers: one program per
+	// syscall and no tmp storage. Needs kernel BTF and amd64.
+	BackendFentry Backend = "fentry"
+	// BackendKprobe uses kprobe/kretprobe pairs on the __x64_sys_* wrappers.
+	BackendKprobe Backend = "kprobe"
+	// BackendTracepoint uses the syscalls tracepoints and works everywhere.
+	BackendTracepoint Backend = "tracepoint"
+)
+
+// fentryProbeSymbol must exist in kernel BTF for the fentry backend.
+const fentryProbeSymbol = "__x64_sys_openat"
+
+// probeBackends returns the backend of each probe, by name, when b is
+// selected. With fentry, a probe whose target is missing from kernel BTF,
+// e.g. fchmodat2 before Linux 6.6, falls back to tracepoints; if those are
+// missing too the probe fails to attach like any other.
+func probeBackends(b Backend) map[string]Backend {
+	var spec *btf.Spec
+	if b == BackendFentry {
+		// checkBackend has loaded it once already; it is cached.
+		spec, _ = btf.LoadKernelSpec()
+	}
+
+	res := make(map[string]Backend, len(Probes))
+	for _, p := range Probes {
+		pb := p.backend(b)
+		if pb == BackendFentry {
+			symbol := "__x64_sys_" + p.Name
+			var fn *btf.Func
+			if spec == nil || spec.TypeByName(symbol, &fn) != nil {
+				log.Printf("Probe %s: %s not in kernel BTF, using tracepoints", p.Name, symbol)
+				pb = BackendTracepoint
+			}
+		}
+		res[p.Name] = pb
+	}
+	return res
+}
+
+// resolveBackend turns the configured backend into the one that will be used.
+// Unavailable backends fall back to tracepoints with a log message.
+func resolveBackend(want Backend) (Backend, error) {
+	switch want {
+	case "", BackendAuto:
+		if err := checkBackend(BackendFentry); err != nil {
+			log.Printf("Backend fentry unavailable, using tracepoints: %v", err)
+			return BackendTracepoint, nil
+		}
+		return BackendFentry, nil
+	case BackendTracepoint:
+		return BackendTracepoint, nil
+	case BackendFentry, BackendKprobe:
+		if err := checkBackend(want); err != nil {
+			log.Printf("Backend %s unavailable, using tracepoints: %v", want, err)
+			return BackendTracepoint, nil
+		}
+		return want, nil
+	default:
+		return "", fmt.Errorf("unknown backend %q", want)
+	}
+}
+
+// checkBackend probes the running kernel for what backend b needs.
+func checkBackend(b Backend) error {
+	if !compiledBackends[b] {
+		return fmt.Errorf("disabled at build time")
+	}
+	// The kprobe and fexit programs read syscall arguments from x86-64 pt_regs.
+	if runtime.GOARCH != "amd64" {
+		return fmt.Errorf("not supported on %s", runtime.GOARCH)
+	}
+
+	switch b {
+	case BackendFentry:
+		if err := features.HaveProgramType(ebpf.Tracing); err != nil {
+			return fmt.Errorf("tracing programs: %v", err)
+		}
+		spec, err := btf.LoadKernelSpec()
+		if err != nil {
+			return fmt.Errorf("kernel BTF: %v", err)
+		}
+		var fn *btf.Func
+		if err := spec.TypeByName(fentryProbeSymbol, &fn); err != nil {
+			return fmt.Errorf("%s: %v", fentryProbeSymbol, err)
+		}
+	case BackendKprobe:
+		if err := features.HaveProgramType(ebpf.Kprobe); err != nil {
+			return fmt.Errorf("kprobe programs: %v", err)
+		}
+	}
+	return nil
+}
+
+// pruneProgramSpecs drops the programs of unused backends, and the LSM
+// programs unless enforce is set, so that e.g. fexit programs are never
+// loaded on kernels without BTF or without their target function.
+func pruneProgramSpecs(spec *ebpf.CollectionSpec, backends map[string]Backend, enforce bool) {
+	keep := make(map[string]bool)
+	for _, name := range lsmPrograms {
+		keep[name] = enforce
+	}
+	for _, tp := range lsmTracepoints {
+		keep[tp.Program] = enforce
+	}
+	for name := range isolationPrograms {
+		keep[name] = true
+	}
+	for _, p := range Probes {
+		for _, a := range p.attachments(backends[p.Name]) {
+			keep[a.program()] = true
+		}
+	}
+	for name := range spec.Programs {
+		if !keep[name] {
+			delete(spec.Programs, name)
+		}
+	}
+}
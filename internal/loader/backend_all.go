@@ -0,0 +1,9 @@
+//go:build !tracepoint_only
+
+package loader
+
+var compiledBackends = map[Backend]bool{
+	BackendFentry:     true,
+	BackendKprobe:     true,
+	BackendTracepoint: true,
+}
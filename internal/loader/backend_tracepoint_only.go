@@ -0,0 +1,8 @@
+//go:build tracepoint_only
+
+package loader
+
+// Built with -tags tracepoint_only: only the syscall tracepoints are used.
+var compiledBackends = map[Backend]bool{
+	BackendTracepoint: true,
+}
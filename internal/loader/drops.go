@@ -0,0 +1,88 @@
+package loader
+
+import (
+	"diploma/internal/bpf"
+	"diploma/internal/events"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cilium/ebpf"
+)
+
+// DropStats counts events lost in the kernel before reaching user space.
+type DropStats struct {
+	RingBuffer uint64 // bpf_ringbuf_reserve failed
+	TmpStorage uint64 // *_tmp_storage map was full on syscall enter
+}
+
+func readDrops(m *ebpf.Map) (map[events.EventType]DropStats, error) {
+	types := []events.EventType{events.EventDeny}
+	for t := range Decoders() {
+		types = append(types, t)
+	}
+
+	res := make(map[events.EventType]DropStats)
+	for _, t := range types {
+		var perCPU []bpf.TraceDropStats
+		if err := m.Lookup(uint32(t), &perCPU); err != nil {
+			return nil, fmt.Errorf("lookup drop counters for %s: %v", t, err)
+		}
+
+		var total DropStats
+		for _, s := range perCPU {
+			total.RingBuffer += s.Ringbuf
+			total.TmpStorage += s.TmpStorage
+		}
+		res[t] = total
+	}
+	return res, nil
+}
+
+// Drops returns the cumulative per-type drop counters summed over all CPUs.
+func (r *LoaderResult) Drops() (map[events.EventType]DropStats, error) {
+	return readDrops(r.dropCounters)
+}
+
+// WatchDrops polls the drop counters every interval, passes the totals to
+// report and logs every type whose counters grew since the previous poll.
+func (r *LoaderResult) WatchDrops(interval time.Duration, report func(map[events.EventType]DropStats)) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		prev := make(map[events.EventType]DropStats)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			drops, err := r.Drops()
+			if err != nil {
+				log.Printf("Drop counters error: %v", err)
+				continue
+			}
+
+			for t := range drops {
+				cur, old := drops[t], prev[t]
+				if cur.RingBuffer > old.RingBuffer || cur.TmpStorage > old.TmpStorage {
+					log.Printf("[DROPS] %s: ringbuf +%d (total %d), tmp_storage +%d (total %d)",
+						t, cur.RingBuffer-old.RingBuffer, cur.RingBuffer,
+						cur.TmpStorage-old.TmpStorage, cur.TmpStorage)
+				}
+			}
+			prev = drops
+
+			if report != nil {
+				report(drops)
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
@@ -0,0 +1,489 @@
+package loader
+
+import (
+	"diploma/internal/analyzer"
+	"diploma/internal/bpf"
+	"diploma/internal/events"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/link"
+)
+
+// EnforceMode selects what the LSM programs do with operations matching a
+// deny rule. Values must match enum enforce_mode in trace.c.in.
+type EnforceMode uint32
+
+const (
+	EnforceOff EnforceMode = iota
+	// EnforceDryRun reports matching operations without blocking them.
+	EnforceDryRun
+	// EnforceOn fails matching operations with EPERM.
+	EnforceOn
+)
+
+// Must match DENY_MAX_RULES and enum deny_hook in trace.c.in.
+const denyMaxRules = 4
+
+var lsmPrograms = []string{
+	"lsm_file_open",
+	"lsm_bprm_check_security",
+	"lsm_socket_connect",
+	"lsm_ptrace_access_check",
+}
+
+// lsmTracepoints mark the threads in ptrace(PTRACE_ATTACH/PTRACE_SEIZE) for
+// lsm_ptrace_access_check, which also runs for other ptrace access checks.
+// They are loaded and attached with the LSM programs, before them.
+var lsmTracepoints = []Tracepoint{
+	{Group: "syscalls", Name: "sys_enter_ptrace", Program: "lsm_enter_ptrace"},
+	{Group: "syscalls", Name: "sys_exit_ptrace", Program: "lsm_exit_ptrace"},
+}
+
+func ParseEnforceMode(s string) (EnforceMode, error) {
+	switch s {
+	case "", "off":
+		return EnforceOff, nil
+	case "dry_run":
+		return EnforceDryRun, nil
+	case "enforce":
+		return EnforceOn, nil
+	}
+	return 0, fmt.Errorf("unknown enforcement mode %q (want off, dry_run or enforce)", s)
+}
+
+func (m EnforceMode) String() string {
+	switch m {
+	case EnforceDryRun:
+		return "dry_run"
+	case EnforceOn:
+		return "enforce"
+	}
+	return "off"
+}
+
+// checkLSM reports whether BPF LSM programs can be attached.
+func checkLSM() error {
+	if err := features.HaveProgramType(ebpf.LSM); err != nil {
+		return fmt.Errorf("LSM programs: %v", err)
+	}
+	data, err := os.ReadFile("/sys/kernel/security/lsm")
+	if err != nil {
+		return fmt.Errorf("read active LSMs: %v", err)
+	}
+	for _, name := range strings.Split(strings.TrimSpace(string(data)), ",") {
+		if name == "bpf" {
+			return nil
+		}
+	}
+	return fmt.Errorf("bpf is not an active LSM (%s); add it to the lsm= boot parameter", strings.TrimSpace(string(data)))
+}
+
+func attachLSM(programs map[string]*ebpf.Program) ([]link.Link, error) {
+	var links []link.Link
+	for _, tp := range lsmTracepoints {
+		l, err := tp.attach(programs)
+		if err != nil {
+			closeLinks(links)
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	for _, name := range lsmPrograms {
+		prog, err := lookupProgram(programs, name)
+		if err == nil {
+			var l link.Link
+			l, err = link.AttachLSM(link.LSMOptions{Program: prog})
+			if err == nil {
+				links = append(links, l)
+				continue
+			}
+		}
+		closeLinks(links)
+		return nil, fmt.Errorf("attach %s: %v", name, err)
+	}
+	return links, nil
+}
+
+// DenyPolicy is the kernel-side form of the rules with action: deny. Unlike
+// the openat pre-filter it must never be wider than the rules, so a deny rule
+// is enforced only if all of its conditions can be checked exactly at the LSM
+// hook; other deny rules stay alert-only and are listed in Skipped.
+type DenyPolicy struct {
+	// Rules maps the rule ids used in the kernel to rule names.
+	Rules []string
+	// Skipped explains, by rule name, why a deny rule is not enforced.
+	Skipped map[string]string
+
+	openPaths map[string][]denyPath
+	execPaths map[string][]denyPath
+	connect   map[bpf.TraceDenyAddrKey]uint32
+	// Rules (id + 1) denying PTRACE_ATTACH and PTRACE_SEIZE.
+	ptraceAttachRule uint32
+	ptraceSeizeRule  uint32
+	// The first open and exec path rules (id + 1) are reported for files
+	// whose path the kernel cannot resolve; such files are denied.
+	openRule uint32
+	execRule uint32
+}
+
+type denyPath struct {
+	exact bool
+	flags uint32
+	rule  uint32
+}
+
+// denyRule is a compiled rule before it is merged into the policy.
+type denyRule struct {
+	openPaths map[string]denyPath
+	execPaths map[string]denyPath
+	addrs     []bpf.TraceDenyAddrKey
+	// ptrace holds the denied requests, PTRACE_ATTACH and PTRACE_SEIZE.
+	ptrace map[string]bool
+}
+
+// Open flags that survive into file->f_flags at the file_open hook.
+var lsmOpenFlags = map[string]bool{"O_WRONLY": true, "O_RDWR": true, "O_APPEND": true}
+
+var lsmPtraceRequests = map[string]bool{"PTRACE_ATTACH": true, "PTRACE_SEIZE": true}
+
+func BuildDenyPolicy(rules []analyzer.Rule) DenyPolicy {
+	p := DenyPolicy{
+		Skipped:   make(map[string]string),
+		openPaths: make(map[string][]denyPath),
+		execPaths: make(map[string][]denyPath),
+		connect:   make(map[bpf.TraceDenyAddrKey]uint32),
+	}
+
+	for _, rule := range rules {
+		if rule.Action != analyzer.ActionDeny {
+			continue
+		}
+		id := uint32(len(p.Rules))
+		compiled, err := compileDenyRule(rule, id)
+		if err == nil {
+			err = p.merge(compiled, id)
+		}
+		if err != nil {
+			p.Skipped[rule.Name] = err.Error()
+			continue
+		}
+		p.Rules = append(p.Rules, rule.Name)
+	}
+	return p
+}
+
+func compileDenyRule(rule analyzer.Rule, id uint32) (denyRule, error) {
+	var res denyRule
+	if rule.Sequence != nil || rule.Threshold != nil {
+		return res, fmt.Errorf("sequence and threshold rules cannot be enforced")
+	}
+	if len(rule.EventTypes) == 0 {
+		return res, fmt.Errorf("no event types")
+	}
+	for _, t := range rule.EventTypes {
+		var err error
+		switch t {
+		case "openat":
+			res.openPaths, err = compilePathRule(rule, id, []string{"fd.name", "evt.arg.filename"}, true)
+		case "execve":
+			res.execPaths, err = compilePathRule(rule, id, []string{"proc.exepath", "evt.arg.filename"}, false)
+		case "connect":
+			res.addrs, err = compileConnectRule(rule)
+		case "ptrace":
+			res.ptrace, err = compilePtraceRule(rule)
+		default:
+			err = fmt.Errorf("no LSM hook for %s events", t)
+		}
+		if err != nil {
+			return denyRule{}, err
+		}
+	}
+	return res, nil
+}
+
+func compilePathRule(rule analyzer.Rule, id uint32, pathFields []string, allowFlags bool) (map[string]denyPath, error) {
+	var values []string
+	exact := false
+	var flags uint32
+
+	for _, cond := range rule.Conditions {
+		switch {
+		case contains(pathFields, cond.Field):
+			if values != nil {
+				return nil, fmt.Errorf("more than one path condition")
+			}
+			switch cond.Operator {
+			case "=":
+				values, exact = []string{cond.Value}, true
+			case "in":
+				values, exact = splitValues(cond.Value), true
+			case "startswith":
+				values = []string{cond.Value}
+			default:
+				return nil, fmt.Errorf("operator %q on %s cannot be enforced", cond.Operator, cond.Field)
+			}
+		case allowFlags && cond.Field == "evt.arg.flags" && cond.Operator == "contains" && lsmOpenFlags[cond.Value]:
+			m, _ := events.OpenFlagMask(cond.Value)
+			flags |= m
+		default:
+			return nil, fmt.Errorf("condition %s %s %q cannot be enforced", cond.Field, cond.Operator, cond.Value)
+		}
+	}
+	if values == nil {
+		return nil, fmt.Errorf("no path condition")
+	}
+
+	res := make(map[string]denyPath, len(values))
+	for _, v := range values {
+		if !strings.HasPrefix(v, "/") || len(v) >= filterPathLen {
+			return nil, fmt.Errorf("path %q must be absolute and shorter than %d bytes", v, filterPathLen)
+		}
+		res[v] = denyPath{exact: exact, flags: flags, rule: id}
+	}
+	return res, nil
+}
+
+func compileConnectRule(rule analyzer.Rule) ([]bpf.TraceDenyAddrKey, error) {
+	ips := []uint32{0}
+	ports := []uint16{0}
+
+	for _, cond := range rule.Conditions {
+		if cond.Operator != "=" && cond.Operator != "in" {
+			return nil, fmt.Errorf("operator %q on %s cannot be enforced", cond.Operator, cond.Field)
+		}
+		switch cond.Field {
+		case "fd.ip", "fd.sip":
+			ips = nil
+			for _, v := range splitValues(cond.Value) {
+				ip := net.ParseIP(v).To4()
+				if ip == nil || ip.IsUnspecified() {
+					return nil, fmt.Errorf("invalid IPv4 address %q", v)
+				}
+				// Same byte order as sockaddr_in.sin_addr.
+				ips = append(ips, binary.NativeEndian.Uint32(ip))
+			}
+		case "fd.port", "fd.sport":
+			ports = nil
+			for _, v := range splitValues(cond.Value) {
+				n, err := strconv.ParseUint(v, 10, 16)
+				if err != nil || n == 0 {
+					return nil, fmt.Errorf("invalid port %q", v)
+				}
+				var b [2]byte
+				binary.BigEndian.PutUint16(b[:], uint16(n))
+				ports = append(ports, binary.NativeEndian.Uint16(b[:]))
+			}
+		default:
+			return nil, fmt.Errorf("condition %s %s %q cannot be enforced", cond.Field, cond.Operator, cond.Value)
+		}
+	}
+	if len(ips) == 1 && ips[0] == 0 && len(ports) == 1 && ports[0] == 0 {
+		return nil, fmt.Errorf("no address or port condition")
+	}
+
+	var res []bpf.TraceDenyAddrKey
+	for _, ip := range ips {
+		for _, port := range ports {
+			res = append(res, bpf.TraceDenyAddrKey{Ip: ip, Port: port})
+		}
+	}
+	return res, nil
+}
+
+// compilePtraceRule accepts rules on PTRACE_ATTACH/PTRACE_SEIZE only and
+// returns the requests matched by all conditions. The kernel denies only
+// these requests of the ptrace syscall; other access checks of the same hook,
+// e.g. opening /proc/<pid>/mem or process_vm_readv, are never denied.
+func compilePtraceRule(rule analyzer.Rule) (map[string]bool, error) {
+	var requests map[string]bool
+	for _, cond := range rule.Conditions {
+		if cond.Field != "evt.arg.request" || (cond.Operator != "=" && cond.Operator != "in") {
+			return nil, fmt.Errorf("condition %s %s %q cannot be enforced", cond.Field, cond.Operator, cond.Value)
+		}
+		values := make(map[string]bool)
+		for _, v := range splitValues(cond.Value) {
+			if !lsmPtraceRequests[v] {
+				return nil, fmt.Errorf("ptrace request %s cannot be enforced", v)
+			}
+			if requests == nil || requests[v] {
+				values[v] = true
+			}
+		}
+		requests = values
+	}
+	if requests == nil {
+		return nil, fmt.Errorf("no evt.arg.request condition")
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("conditions match no ptrace request")
+	}
+	return requests, nil
+}
+
+// merge adds a compiled rule unless it would overflow a trie entry.
+func (p *DenyPolicy) merge(r denyRule, id uint32) error {
+	openPaths, err := mergeDenyPaths(p.openPaths, r.openPaths)
+	if err != nil {
+		return err
+	}
+	execPaths, err := mergeDenyPaths(p.execPaths, r.execPaths)
+	if err != nil {
+		return err
+	}
+	p.openPaths, p.execPaths = openPaths, execPaths
+
+	for _, k := range r.addrs {
+		if _, ok := p.connect[k]; !ok {
+			p.connect[k] = id
+		}
+	}
+	if r.ptrace["PTRACE_ATTACH"] && p.ptraceAttachRule == 0 {
+		p.ptraceAttachRule = id + 1
+	}
+	if r.ptrace["PTRACE_SEIZE"] && p.ptraceSeizeRule == 0 {
+		p.ptraceSeizeRule = id + 1
+	}
+	if len(r.openPaths) > 0 && p.openRule == 0 {
+		p.openRule = id + 1
+	}
+	if len(r.execPaths) > 0 && p.execRule == 0 {
+		p.execRule = id + 1
+	}
+	return nil
+}
+
+func mergeDenyPaths(paths map[string][]denyPath, add map[string]denyPath) (map[string][]denyPath, error) {
+	if len(add) == 0 {
+		return paths, nil
+	}
+	res := make(map[string][]denyPath, len(paths)+len(add))
+	for path, slots := range paths {
+		res[path] = slots
+	}
+	for path, dp := range add {
+		res[path] = append(res[path][:len(res[path]):len(res[path])], dp)
+	}
+	for path := range res {
+		if n := len(inheritedDenyPaths(res, path)); n > denyMaxRules {
+			return nil, fmt.Errorf("more than %d deny rules match path %q", denyMaxRules, path)
+		}
+	}
+	return res, nil
+}
+
+// inheritedDenyPaths returns the slots of the trie entry for path. The trie
+// only returns the longest match, so each entry also carries the prefix slots
+// of the shorter entries it extends.
+func inheritedDenyPaths(paths map[string][]denyPath, path string) []denyPath {
+	var res []denyPath
+	for q, slots := range paths {
+		for _, s := range slots {
+			if q == path || (!s.exact && strings.HasPrefix(path, q)) {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
+
+// ApplyDenyPolicy replaces the kernel deny maps and sets the enforcement
+// mode. Enforcement is switched off while the maps are rewritten.
+func (r *LoaderResult) ApplyDenyPolicy(p DenyPolicy, mode EnforceMode) error {
+	zero := uint32(0)
+	if err := r.enforceConfig.Put(zero, bpf.TraceEnforceConfig{}); err != nil {
+		return fmt.Errorf("disable enforcement: %v", err)
+	}
+	if mode == EnforceOff {
+		return nil
+	}
+	if r.lsmLinks == nil {
+		return fmt.Errorf("enforcement requested but LSM programs are not loaded")
+	}
+
+	if err := clearMap[bpf.TracePathFilterKey, bpf.TraceDenyPathValue](r.denyOpenPaths); err != nil {
+		return fmt.Errorf("clear deny_open_paths: %v", err)
+	}
+	if err := clearMap[bpf.TracePathFilterKey, bpf.TraceDenyPathValue](r.denyExecPaths); err != nil {
+		return fmt.Errorf("clear deny_exec_paths: %v", err)
+	}
+	if err := clearMap[bpf.TraceDenyAddrKey, uint32](r.denyConnect); err != nil {
+		return fmt.Errorf("clear deny_connect: %v", err)
+	}
+
+	if err := putDenyPaths(r.denyOpenPaths, p.openPaths); err != nil {
+		return err
+	}
+	if err := putDenyPaths(r.denyExecPaths, p.execPaths); err != nil {
+		return err
+	}
+	for k, id := range p.connect {
+		if err := r.denyConnect.Put(k, id); err != nil {
+			return fmt.Errorf("add deny address: %v", err)
+		}
+	}
+
+	cfg := bpf.TraceEnforceConfig{
+		Mode:             uint32(mode),
+		PtraceAttachRule: p.ptraceAttachRule,
+		PtraceSeizeRule:  p.ptraceSeizeRule,
+		OpenRule:         p.openRule,
+		ExecRule:         p.execRule,
+	}
+	if err := r.enforceConfig.Put(zero, cfg); err != nil {
+		return fmt.Errorf("enable enforcement: %v", err)
+	}
+	return nil
+}
+
+func putDenyPaths(m *ebpf.Map, paths map[string][]denyPath) error {
+	keys := make([]string, 0, len(paths))
+	for p := range paths {
+		keys = append(keys, p)
+	}
+	sort.Strings(keys)
+
+	for _, path := range keys {
+		slots := inheritedDenyPaths(paths, path)
+		k := bpf.TracePathFilterKey{Prefixlen: uint32(len(path) * 8)}
+		for i := 0; i < len(path); i++ {
+			k.Path[i] = int8(path[i])
+		}
+		v := bpf.TraceDenyPathValue{PrefixLen: uint32(len(path)), Count: uint32(len(slots))}
+		for i, s := range slots {
+			if s.exact {
+				v.ExactBits |= 1 << i
+			}
+			v.FlagMasks[i] = s.flags
+			v.RuleIds[i] = s.rule
+		}
+		if err := m.Put(k, v); err != nil {
+			return fmt.Errorf("add deny path %q: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func splitValues(value string) []string {
+	var res []string
+	for _, v := range strings.Split(value, ",") {
+		res = append(res, strings.TrimSpace(v))
+	}
+	return res
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package loader
+
+import (
+	"diploma/internal/bpf"
+	"diploma/internal/config"
+	"fmt"
+)
+
+// Must match EXECVE_ARGS_MAX and EXECVE_ENV_MAX in trace.c.in.
+const (
+	MaxExecveArgsLen = 4096
+	MaxExecveEnvLen  = 2048
+)
+
+// ApplyExecveLimits sets how many bytes of arguments and environment each
+// execve record carries. A MaxEnvLen of 0 leaves the environment out.
+func (r *LoaderResult) ApplyExecveLimits(cfg config.ExecveConfig) error {
+	if cfg.MaxArgsLen == 0 || cfg.MaxArgsLen > MaxExecveArgsLen {
+		return fmt.Errorf("max_args_len %d must be between 1 and %d", cfg.MaxArgsLen, MaxExecveArgsLen)
+	}
+	if cfg.MaxEnvLen > MaxExecveEnvLen {
+		return fmt.Errorf("max_env_len %d must be at most %d", cfg.MaxEnvLen, MaxExecveEnvLen)
+	}
+
+	limits := bpf.TraceExecveConfig{
+		MaxArgsLen: cfg.MaxArgsLen,
+		MaxEnvLen:  cfg.MaxEnvLen,
+	}
+	zero := uint32(0)
+	if err := r.execveConfig.Put(zero, limits); err != nil {
+		return fmt.Errorf("execve config: %v", err)
+	}
+	return nil
+}
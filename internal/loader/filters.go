@@ -0,0 +1,225 @@
+package loader
+
+import (
+	"diploma/internal/analyzer"
+	"diploma/internal/bpf"
+	"diploma/internal/events"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cilium/ebpf"
+)
+
+// Must match FILTER_PATH_LEN and FILTER_MAX_MASKS in trace.c.in.
+const (
+	filterPathLen  = 64
+	filterMaxMasks = 8
+)
+
+// OpenatFilter describes which openat events are worth sending to user space.
+// It is a superset of what the rules can match: conditions that cannot be
+// checked in the kernel are dropped, never tightened. Path prefixes are
+// compared against the path the kernel resolved for the opened file, which is
+// what fd.name holds; for failed calls, against the absolute name as passed.
+// Relative names of failed calls always pass. Conditions on the raw name
+// (evt.rawarg.filename) give no prefix, which disables the filter.
+type OpenatFilter struct {
+	Enabled bool
+	// Reason explains why the filter is disabled.
+	Reason string
+	// AnyPath holds flag masks of rules without a usable path condition.
+	AnyPath []uint32
+	// Prefixes maps a path prefix to the flag masks of the rules using it.
+	Prefixes map[string][]uint32
+}
+
+func BuildOpenatFilter(rules []analyzer.Rule) OpenatFilter {
+	f := OpenatFilter{
+		Enabled:  true,
+		Prefixes: make(map[string][]uint32),
+	}
+
+	used := false
+	var parts []analyzer.Rule
+	for _, rule := range rules {
+		parts = append(parts, rule.EventRules()...)
+	}
+	for _, rule := range parts {
+		if !rule.MatchesType("openat") {
+			continue
+		}
+		used = true
+
+		prefixes, mask := openatRuleTerm(rule)
+		if len(prefixes) == 0 {
+			if mask == 0 {
+				return OpenatFilter{Reason: fmt.Sprintf("rule %q has no path prefix or flag condition", rule.Name)}
+			}
+			f.AnyPath = appendMask(f.AnyPath, mask)
+			continue
+		}
+		for _, p := range prefixes {
+			f.Prefixes[p] = appendMask(f.Prefixes[p], mask)
+		}
+	}
+
+	if !used {
+		// No openat rules: nothing needs to leave the kernel.
+		return f
+	}
+	if len(f.AnyPath) > filterMaxMasks {
+		return OpenatFilter{Reason: fmt.Sprintf("too many distinct flag masks (%d > %d)", len(f.AnyPath), filterMaxMasks)}
+	}
+	return f
+}
+
+// openatRuleTerm returns the path prefixes and required open flags of a rule.
+// Only the first prefix-like path condition is used; any one of them is enough
+// for a superset.
+func openatRuleTerm(rule analyzer.Rule) ([]string, uint32) {
+	var prefixes []string
+	var mask uint32
+
+	for _, cond := range rule.Conditions {
+		switch cond.Field {
+		case "evt.arg.filename", "fd.name":
+			if prefixes != nil {
+				continue
+			}
+			var values []string
+			switch cond.Operator {
+			case "=", "startswith":
+				values = []string{cond.Value}
+			case "in":
+				for _, v := range strings.Split(cond.Value, ",") {
+					values = append(values, strings.TrimSpace(v))
+				}
+			default:
+				continue
+			}
+			if p := absolutePrefixes(values); p != nil {
+				prefixes = p
+			}
+		case "evt.arg.flags":
+			if cond.Operator != "contains" {
+				continue
+			}
+			if m, ok := events.OpenFlagMask(cond.Value); ok {
+				mask |= m
+			}
+		}
+	}
+	return prefixes, mask
+}
+
+func absolutePrefixes(values []string) []string {
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		if !strings.HasPrefix(v, "/") {
+			return nil
+		}
+		if len(v) > filterPathLen {
+			v = v[:filterPathLen]
+		}
+		res = append(res, v)
+	}
+	return res
+}
+
+func appendMask(masks []uint32, mask uint32) []uint32 {
+	for _, m := range masks {
+		if m == mask {
+			return masks
+		}
+	}
+	return append(masks, mask)
+}
+
+// ApplyOpenatFilter replaces the kernel-side openat filter. The filter is
+// switched off while the prefix map is rewritten so no events are lost.
+func (r *LoaderResult) ApplyOpenatFilter(f OpenatFilter) error {
+	zero := uint32(0)
+	if err := r.openatFilter.Put(zero, bpf.TraceOpenatFilterConfig{}); err != nil {
+		return fmt.Errorf("disable openat filter: %v", err)
+	}
+	if !f.Enabled {
+		return nil
+	}
+
+	if err := clearMap[bpf.TracePathFilterKey, bpf.TracePathFilterValue](r.openatPathFilter); err != nil {
+		return fmt.Errorf("clear openat path filter: %v", err)
+	}
+
+	prefixes := make([]string, 0, len(f.Prefixes))
+	for p := range f.Prefixes {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+
+	for _, p := range prefixes {
+		// The trie only returns the longest match, so each entry also carries
+		// the masks of shorter prefixes it extends.
+		var masks []uint32
+		for _, q := range prefixes {
+			if strings.HasPrefix(p, q) {
+				for _, m := range f.Prefixes[q] {
+					masks = appendMask(masks, m)
+				}
+			}
+		}
+		if len(masks) > filterMaxMasks || containsMask(masks, 0) {
+			masks = []uint32{0}
+		}
+
+		k := bpf.TracePathFilterKey{Prefixlen: uint32(len(p) * 8)}
+		for i := 0; i < len(p); i++ {
+			k.Path[i] = int8(p[i])
+		}
+		v := bpf.TracePathFilterValue{Count: uint32(len(masks))}
+		copy(v.FlagMasks[:], masks)
+
+		if err := r.openatPathFilter.Put(k, v); err != nil {
+			return fmt.Errorf("add openat path prefix %q: %v", p, err)
+		}
+	}
+
+	cfg := bpf.TraceOpenatFilterConfig{
+		Enabled:      1,
+		AnyPathCount: uint32(len(f.AnyPath)),
+	}
+	copy(cfg.AnyPathMasks[:], f.AnyPath)
+	if err := r.openatFilter.Put(zero, cfg); err != nil {
+		return fmt.Errorf("enable openat filter: %v", err)
+	}
+	return nil
+}
+
+func containsMask(masks []uint32, mask uint32) bool {
+	for _, m := range masks {
+		if m == mask {
+			return true
+		}
+	}
+	return false
+}
+
+// clearMap deletes all entries of a hash or trie map.
+func clearMap[K, V any](m *ebpf.Map) error {
+	var key K
+	var value V
+	var keys []K
+	iter := m.Iterate()
+	for iter.Next(&key, &value) {
+		keys = append(keys, key)
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := m.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
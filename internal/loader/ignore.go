@@ -0,0 +1,88 @@
+package loader
+
+import (
+	"diploma/internal/bpf"
+	"diploma/internal/config"
+	"fmt"
+	"log"
+	"syscall"
+)
+
+// Must match enum ignore_kind in trace.c.in.
+const (
+	ignoreComms   = 1 << 0
+	ignoreUids    = 1 << 1
+	ignoreCgroups = 1 << 2
+	ignoreExes    = 1 << 3
+)
+
+// ApplyIgnoreList writes the ignore configuration into the BPF maps consulted
+// by every trace_enter_* program. Executables that cannot be stat'ed are
+// skipped with a warning.
+func (r *LoaderResult) ApplyIgnoreList(ignore config.IgnoreConfig) error {
+	one := uint8(1)
+	var kinds uint32
+
+	for _, comm := range ignore.Comms {
+		var key [16]byte
+		copy(key[:15], comm)
+		if err := r.ignoreComms.Put(key, one); err != nil {
+			return fmt.Errorf("ignore comm %q: %v", comm, err)
+		}
+		kinds |= ignoreComms
+	}
+
+	for _, uid := range ignore.Uids {
+		if err := r.ignoreUids.Put(uid, one); err != nil {
+			return fmt.Errorf("ignore uid %d: %v", uid, err)
+		}
+		kinds |= ignoreUids
+	}
+
+	for _, id := range ignore.CgroupIds {
+		if err := r.ignoreCgroups.Put(id, one); err != nil {
+			return fmt.Errorf("ignore cgroup %d: %v", id, err)
+		}
+		kinds |= ignoreCgroups
+	}
+
+	for _, path := range ignore.ExePaths {
+		key, err := exeKey(path)
+		if err != nil {
+			log.Printf("Skipping ignored executable %s: %v", path, err)
+			continue
+		}
+		if err := r.ignoreExes.Put(key, one); err != nil {
+			return fmt.Errorf("ignore executable %s: %v", path, err)
+		}
+		kinds |= ignoreExes
+	}
+
+	zero := uint32(0)
+	if err := r.ignoreConfig.Put(zero, kinds); err != nil {
+		return fmt.Errorf("ignore config: %v", err)
+	}
+	return nil
+}
+
+func exeKey(path string) (bpf.TraceExeKey, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return bpf.TraceExeKey{}, err
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFREG {
+		return bpf.TraceExeKey{}, fmt.Errorf("not a regular file")
+	}
+	return bpf.TraceExeKey{
+		Ino: uint64(st.Ino),
+		Dev: kernelDev(uint64(st.Dev)),
+	}, nil
+}
+
+// kernelDev converts a user-space dev_t into the kernel's internal
+// MKDEV(major, minor) encoding stored in super_block.s_dev.
+func kernelDev(dev uint64) uint64 {
+	major := ((dev >> 8) & 0xfff) | ((dev >> 32) &^ 0xfff)
+	minor := (dev & 0xff) | ((dev >> 12) &^ 0xff)
+	return major<<20 | minor
+}
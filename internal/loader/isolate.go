@@ -0,0 +1,56 @@
+package loader
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+)
+
+// Programs attached by IsolateCgroup; they are always loaded.
+var isolationPrograms = map[string]ebpf.AttachType{
+	"cgroup_drop_egress":  ebpf.AttachCGroupInetEgress,
+	"cgroup_drop_ingress": ebpf.AttachCGroupInetIngress,
+}
+
+// IsolateCgroup drops all network traffic of the cgroup at path (a cgroup v2
+// directory) until the monitor exits. Isolating a cgroup twice is a no-op.
+func (r *LoaderResult) IsolateCgroup(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.isolated[path] != nil {
+		return nil
+	}
+
+	var links []link.Link
+	for name, attach := range isolationPrograms {
+		prog, err := lookupProgram(r.programs, name)
+		if err != nil {
+			closeLinks(links)
+			return err
+		}
+		l, err := link.AttachCgroup(link.CgroupOptions{
+			Path:    path,
+			Attach:  attach,
+			Program: prog,
+		})
+		if err != nil {
+			closeLinks(links)
+			return fmt.Errorf("attach %s to %s: %v", name, path, err)
+		}
+		links = append(links, l)
+	}
+	r.isolated[path] = links
+	return nil
+}
+
+func (r *LoaderResult) releaseIsolation() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for path, links := range r.isolated {
+		closeLinks(links)
+		delete(r.isolated, path)
+	}
+}
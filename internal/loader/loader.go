@@ -2,188 +2,174 @@ package loader
 
 import (
 	"diploma/internal/bpf"
+	"diploma/internal/events"
 	"fmt"
+	"os"
+	"sync"
 
+	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/ringbuf"
 )
 
+type Options struct {
+	RingBufferSize uint32
+	Backend        Backend
+	// Events selects the event types whose probes are attached.
+	Events []events.EventType
+	// Enforce loads and attaches the LSM programs. They stay inactive until
+	// ApplyDenyPolicy sets a mode other than EnforceOff.
+	Enforce bool
+}
+
 type LoaderResult struct {
-	OpenatReader  *ringbuf.Reader
-	ExecveReader  *ringbuf.Reader
-	ConnectReader *ringbuf.Reader
-	AcceptReader  *ringbuf.Reader
-	PtraceReader  *ringbuf.Reader
-	MemfdReader   *ringbuf.Reader
-	ChmodReader   *ringbuf.Reader
+	// Readers holds one reader per ring buffer referenced by Probes.
+	Readers map[string]*ringbuf.Reader
+
+	mu       sync.Mutex
+	backend  Backend
+	backends map[string]Backend // by probe name
+	programs map[string]*ebpf.Program
+	attached map[string][]link.Link // by probe name
+	enabled  map[events.EventType]bool
+	failed   map[string]error
+	lsmLinks []link.Link
+	isolated map[string][]link.Link // by cgroup path
+
+	dropCounters     *ebpf.Map
+	openatFilter     *ebpf.Map
+	openatPathFilter *ebpf.Map
+	execveConfig     *ebpf.Map
+	ignoreConfig     *ebpf.Map
+	ignoreComms      *ebpf.Map
+	ignoreUids       *ebpf.Map
+	ignoreCgroups    *ebpf.Map
+	ignoreExes       *ebpf.Map
+	enforceConfig    *ebpf.Map
+	denyOpenPaths    *ebpf.Map
+	denyExecPaths    *ebpf.Map
+	denyConnect      *ebpf.Map
 }
 
-func Setup() (*LoaderResult, func(), error) {
-	objs := bpf.TraceObjects{}
-	if err := bpf.LoadTraceObjects(&objs, nil); err != nil {
-		return nil, nil, fmt.Errorf("loading objects: %v", err)
+// Setup loads the BPF objects, attaches the probes for opts.Events and opens
+// the ring buffer readers. On any failure everything created so far is
+// released before the error is returned.
+func Setup(opts Options) (res *LoaderResult, cleanup func(), err error) {
+	var undo []func()
+	release := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
 	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
 
-	var links []link.Link
-
-	// --- 1. OPENAT ---
-	l1, err := link.Tracepoint("syscalls", "sys_enter_openat", objs.TraceEnterOpenat, nil)
-	if err != nil {
-		objs.Close()
+	if err := validateRingBufferSize(opts.RingBufferSize); err != nil {
 		return nil, nil, err
 	}
-	links = append(links, l1)
 
-	l2, err := link.Tracepoint("syscalls", "sys_exit_openat", objs.TraceExitOpenat, nil)
+	backend, err := resolveBackend(opts.Backend)
 	if err != nil {
 		return nil, nil, err
 	}
-	links = append(links, l2)
 
-	// --- 2. EXECVE ---
-	l3, err := link.Tracepoint("syscalls", "sys_enter_execve", objs.TraceEnterExecve, nil)
-	if err != nil {
-		return nil, nil, err
+	if opts.Enforce {
+		if err := checkLSM(); err != nil {
+			return nil, nil, fmt.Errorf("enforcement unavailable: %v", err)
+		}
 	}
-	links = append(links, l3)
 
-	l4, err := link.Tracepoint("syscalls", "sys_exit_execve", objs.TraceExitExecve, nil)
+	spec, err := bpf.LoadTrace()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("loading spec: %v", err)
 	}
-	links = append(links, l4)
+	spec.Maps["events"].MaxEntries = opts.RingBufferSize
+	backends := probeBackends(backend)
+	pruneProgramSpecs(spec, backends, opts.Enforce)
 
-	l5, err := link.Tracepoint("syscalls", "sys_enter_connect", objs.TraceEnterConnect, nil)
+	coll, err := ebpf.NewCollection(spec)
 	if err != nil {
-		for _, l := range links {
-			l.Close()
-		}
-		objs.Close()
-		return nil, nil, fmt.Errorf("link connect enter: %v", err)
+		return nil, nil, fmt.Errorf("loading objects: %v", err)
 	}
-	links = append(links, l5)
+	undo = append(undo, func() { coll.Close() })
 
-	l6, err := link.Tracepoint("syscalls", "sys_exit_connect", objs.TraceExitConnect, nil)
-	if err != nil {
-		for _, l := range links {
-			l.Close()
-		}
-		objs.Close()
-		return nil, nil, fmt.Errorf("link connect exit: %v", err)
+	res = &LoaderResult{
+		Readers:  make(map[string]*ringbuf.Reader),
+		backend:  backend,
+		backends: backends,
+		programs: coll.Programs,
+		attached: make(map[string][]link.Link),
+		isolated: make(map[string][]link.Link),
 	}
-	links = append(links, l6)
+	undo = append(undo, res.releaseIsolation)
 
-	l7, err := link.Tracepoint("syscalls", "sys_enter_accept4", objs.TraceEnterAccept4, nil)
-	if err != nil {
-		for _, l := range links {
-			l.Close()
+	for _, p := range Probes {
+		if _, ok := res.Readers[p.RingBuffer]; ok {
+			continue
 		}
-		objs.Close()
-		return nil, nil, fmt.Errorf("link accept4 enter: %v", err)
-	}
-	links = append(links, l7)
-
-	l8, err := link.Tracepoint("syscalls", "sys_exit_accept4", objs.TraceExitAccept4, nil)
-	if err != nil {
-		for _, l := range links {
-			l.Close()
+		m, ok := coll.Maps[p.RingBuffer]
+		if !ok {
+			return nil, nil, fmt.Errorf("probe %s: ring buffer %s not found", p.Name, p.RingBuffer)
 		}
-		objs.Close()
-		return nil, nil, fmt.Errorf("link accept4 exit: %v", err)
-	}
-	links = append(links, l8)
-
-	// --- 5. PTRACE (NEW) ---
-	l9, err := link.Tracepoint("syscalls", "sys_enter_ptrace", objs.TraceEnterPtrace, nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("link ptrace enter: %v", err)
-	}
-	links = append(links, l9)
-
-	l10, err := link.Tracepoint("syscalls", "sys_exit_ptrace", objs.TraceExitPtrace, nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("link ptrace exit: %v", err)
-	}
-	links = append(links, l10)
-
-	l11, err := link.Tracepoint("syscalls", "sys_enter_memfd_create", objs.TraceEnterMemfdCreate, nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("link memfd enter: %v", err)
-	}
-	links = append(links, l11)
-
-	l12, err := link.Tracepoint("syscalls", "sys_exit_memfd_create", objs.TraceExitMemfdCreate, nil)
-	if err != nil {
-		return nil, nil, fmt.Errorf("link memfd exit: %v", err)
-	}
-	links = append(links, l12)
-
-	l13, err := link.Tracepoint("syscalls", "sys_enter_fchmodat", objs.TraceEnterFchmodat, nil)
-	if err != nil {
-		return nil, nil, err
+		rd, err := ringbuf.NewReader(m)
+		if err != nil {
+			return nil, nil, fmt.Errorf("probe %s: reader %s: %v", p.Name, p.RingBuffer, err)
+		}
+		undo = append(undo, func() { rd.Close() })
+		res.Readers[p.RingBuffer] = rd
 	}
-	links = append(links, l13)
 
-	l14, err := link.Tracepoint("syscalls", "sys_exit_fchmodat", objs.TraceExitFchmodat, nil)
-	if err != nil {
+	if err := res.SetEnabledEvents(opts.Events); err != nil {
 		return nil, nil, err
 	}
-	links = append(links, l14)
+	undo = append(undo, res.detachAll)
 
-	// --- READERS ---
-	rdOpenat, err := ringbuf.NewReader(objs.OpenatEvents)
-	if err != nil {
-		return nil, nil, err
-	}
-	rdExecve, err := ringbuf.NewReader(objs.ExecveEvents)
-	if err != nil {
-		return nil, nil, err
-	}
-	rdConnect, err := ringbuf.NewReader(objs.ConnectEvents)
-	if err != nil {
-		return nil, nil, err
-	}
-	rdAccept, err := ringbuf.NewReader(objs.AcceptEvents)
-	if err != nil {
-		return nil, nil, err
-	}
-	// Ptrace Reader
-	rdPtrace, err := ringbuf.NewReader(objs.PtraceEvents)
-	if err != nil {
-		return nil, nil, fmt.Errorf("reader ptrace: %v", err)
+	if opts.Enforce {
+		res.lsmLinks, err = attachLSM(res.programs)
+		if err != nil {
+			return nil, nil, err
+		}
+		undo = append(undo, func() { closeLinks(res.lsmLinks) })
+	}
+
+	maps := map[string]**ebpf.Map{
+		"drop_counters":      &res.dropCounters,
+		"openat_filter":      &res.openatFilter,
+		"openat_path_filter": &res.openatPathFilter,
+		"execve_config":      &res.execveConfig,
+		"ignore_config":      &res.ignoreConfig,
+		"ignore_comms":       &res.ignoreComms,
+		"ignore_uids":        &res.ignoreUids,
+		"ignore_cgroups":     &res.ignoreCgroups,
+		"ignore_exes":        &res.ignoreExes,
+		"enforce_config":     &res.enforceConfig,
+		"deny_open_paths":    &res.denyOpenPaths,
+		"deny_exec_paths":    &res.denyExecPaths,
+		"deny_connect":       &res.denyConnect,
+	}
+	for name, dst := range maps {
+		m, ok := coll.Maps[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("map %s not found", name)
+		}
+		*dst = m
 	}
 
-	rdMemfd, err := ringbuf.NewReader(objs.MemfdEvents)
-	if err != nil {
-		return nil, nil, fmt.Errorf("reader memfd: %v", err)
-	}
+	return res, release, nil
+}
 
-	rdChmod, err := ringbuf.NewReader(objs.ChmodEvents)
-	if err != nil {
-		return nil, nil, err
-	}
+// Backend returns the backend chosen at load time.
+func (r *LoaderResult) Backend() Backend {
+	return r.backend
+}
 
-	cleanup := func() {
-		rdOpenat.Close()
-		rdExecve.Close()
-		rdConnect.Close()
-		rdAccept.Close()
-		rdPtrace.Close()
-		rdMemfd.Close()
-		rdChmod.Close()
-		for _, l := range links {
-			l.Close()
-		}
-		objs.Close()
+func validateRingBufferSize(size uint32) error {
+	pageSize := uint32(os.Getpagesize())
+	if size == 0 || size&(size-1) != 0 || size%pageSize != 0 {
+		return fmt.Errorf("ring buffer size %d must be a power of two and a multiple of the page size (%d)", size, pageSize)
 	}
-
-	return &LoaderResult{
-		OpenatReader:  rdOpenat,
-		ExecveReader:  rdExecve,
-		ConnectReader: rdConnect,
-		AcceptReader:  rdAccept,
-		PtraceReader:  rdPtrace,
-		MemfdReader:   rdMemfd,
-		ChmodReader:   rdChmod,
-	}, cleanup, nil
+	return nil
 }
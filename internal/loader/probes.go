@@ -0,0 +1,355 @@
+package loader
+
+import (
+	"diploma/internal/events"
+	"fmt"
+	"log"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+)
+
+// Attachment hooks one BPF program into the kernel.
+type Attachment interface {
+	program() string
+	attach(programs map[string]*ebpf.Program) (link.Link, error)
+}
+
+type Tracepoint struct {
+	Group   string
+	Name    string
+	Program string
+}
+
+// Kprobe attaches to a kernel function entry, or to its return if Return is set.
+type Kprobe struct {
+	Symbol  string
+	Program string
+	Return  bool
+}
+
+// Fexit attaches a tracing program; the target function is fixed by its
+// section name and resolved against kernel BTF at load time.
+type Fexit struct {
+	Program string
+}
+
+// ProbeSpec declares everything needed to trace one syscall. Adding a new
+// syscall means writing its BPF programs and event struct and adding an entry
+// to Probes. Several probes may produce the same event type.
+//
+// Program names follow the syscall name: trace_enter_<name>/trace_exit_<name>
+// for tracepoints, kprobe_enter_<name>/kprobe_exit_<name> on __x64_sys_<name>,
+// and fexit_<name>.
+type ProbeSpec struct {
+	Name  string
+	Event events.EventType
+	// Required probes abort startup when they cannot be attached; optional
+	// ones are reported and skipped.
+	Required bool
+	// Backends lists the backends implemented besides BackendTracepoint, which
+	// every probe has and falls back to.
+	Backends   []Backend
+	RingBuffer string
+	Decode     events.DecodeFunc
+}
+
+var Probes = []ProbeSpec{
+	{
+		Name:       "openat",
+		Event:      events.EventOpenat,
+		Required:   true,
+		Backends:   []Backend{BackendFentry, BackendKprobe},
+		RingBuffer: "events",
+		Decode:     events.Decoder[events.OpenatEvent](),
+	},
+	{
+		Name:     "execve",
+		Event:    events.EventExecve,
+		Required: true,
+		// argv is gone by the time fexit runs, so there is no fexit program.
+		Backends:   []Backend{BackendKprobe},
+		RingBuffer: "events",
+		Decode:     events.Decoder[events.ExecveEvent](),
+	},
+	{
+		Name:       "connect",
+		Event:      events.EventConnect,
+		Required:   false,
+		Backends:   []Backend{BackendFentry, BackendKprobe},
+		RingBuffer: "events",
+		Decode:     events.Decoder[events.ConnectEvent](),
+	},
+	{
+		Name:       "accept4",
+		Event:      events.EventAccept,
+		Required:   false,
+		Backends:   []Backend{BackendFentry, BackendKprobe},
+		RingBuffer: "events",
+		Decode:     events.Decoder[events.AcceptEvent](),
+	},
+	{
+		Name:       "ptrace",
+		Event:      events.EventPtrace,
+		Required:   false,
+		Backends:   []Backend{BackendFentry, BackendKprobe},
+		RingBuffer: "events",
+		Decode:     events.Decoder[events.PtraceEvent](),
+	},
+	{
+		Name:       "memfd_create",
+		Event:      events.EventMemfd,
+		Required:   false,
+		Backends:   []Backend{BackendFentry, BackendKprobe},
+		RingBuffer: "events",
+		Decode:     events.Decoder[events.MemfdEvent](),
+	},
+	{
+		Name:       "fchmodat",
+		Event:      events.EventChmod,
+		Required:   false,
+		Backends:   []Backend{BackendFentry, BackendKprobe},
+		RingBuffer: "events",
+		Decode:     events.Decoder[events.ChmodEvent](),
+	},
+	{
+		Name:       "fchmodat2",
+		Event:      events.EventChmod,
+		Required:   false,
+		Backends:   []Backend{BackendFentry, BackendKprobe},
+		RingBuffer: "events",
+		Decode:     events.Decoder[events.ChmodEvent](),
+	},
+}
+
+func hasProbe(t events.EventType) bool {
+	for _, p := range Probes {
+		if p.Event == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Decoders returns one decoder per event type in Probes.
+func Decoders() map[events.EventType]events.DecodeFunc {
+	res := make(map[events.EventType]events.DecodeFunc)
+	for _, p := range Probes {
+		res[p.Event] = p.Decode
+	}
+	return res
+}
+
+// ProbeStatus is one line of the capability report.
+type ProbeStatus struct {
+	Name     string
+	Event    events.EventType
+	Required bool
+	Backend  Backend
+	Enabled  bool
+	Attached bool
+	Err      error
+}
+
+func (tp Tracepoint) program() string { return tp.Program }
+
+func (tp Tracepoint) attach(programs map[string]*ebpf.Program) (link.Link, error) {
+	prog, err := lookupProgram(programs, tp.Program)
+	if err != nil {
+		return nil, err
+	}
+	l, err := link.Tracepoint(tp.Group, tp.Name, prog, nil)
+	if err != nil {
+		return nil, fmt.Errorf("attach tracepoint %s/%s: %v", tp.Group, tp.Name, err)
+	}
+	return l, nil
+}
+
+func (kp Kprobe) program() string { return kp.Program }
+
+func (kp Kprobe) attach(programs map[string]*ebpf.Program) (link.Link, error) {
+	prog, err := lookupProgram(programs, kp.Program)
+	if err != nil {
+		return nil, err
+	}
+	attach, kind := link.Kprobe, "kprobe"
+	if kp.Return {
+		attach, kind = link.Kretprobe, "kretprobe"
+	}
+	l, err := attach(kp.Symbol, prog, nil)
+	if err != nil {
+		return nil, fmt.Errorf("attach %s %s: %v", kind, kp.Symbol, err)
+	}
+	return l, nil
+}
+
+func (fe Fexit) program() string { return fe.Program }
+
+func (fe Fexit) attach(programs map[string]*ebpf.Program) (link.Link, error) {
+	prog, err := lookupProgram(programs, fe.Program)
+	if err != nil {
+		return nil, err
+	}
+	l, err := link.AttachTracing(link.TracingOptions{Program: prog})
+	if err != nil {
+		return nil, fmt.Errorf("attach fexit %s: %v", fe.Program, err)
+	}
+	return l, nil
+}
+
+func lookupProgram(programs map[string]*ebpf.Program, name string) (*ebpf.Program, error) {
+	prog, ok := programs[name]
+	if !ok {
+		return nil, fmt.Errorf("program %s not found", name)
+	}
+	return prog, nil
+}
+
+// backend returns the backend the probe uses when b is selected.
+func (p ProbeSpec) backend(b Backend) Backend {
+	for _, have := range p.Backends {
+		if have == b {
+			return b
+		}
+	}
+	return BackendTracepoint
+}
+
+// attachments lists the programs of the probe for backend b. Exit programs
+// come first, so an enter without a matching exit never fills tmp storage;
+// closeLinks detaches in reverse for the same reason.
+func (p ProbeSpec) attachments(b Backend) []Attachment {
+	switch p.backend(b) {
+	case BackendFentry:
+		return []Attachment{Fexit{"fexit_" + p.Name}}
+	case BackendKprobe:
+		symbol := "__x64_sys_" + p.Name
+		return []Attachment{
+			Kprobe{symbol, "kprobe_exit_" + p.Name, true},
+			Kprobe{symbol, "kprobe_enter_" + p.Name, false},
+		}
+	default:
+		return []Attachment{
+			Tracepoint{"syscalls", "sys_exit_" + p.Name, "trace_exit_" + p.Name},
+			Tracepoint{"syscalls", "sys_enter_" + p.Name, "trace_enter_" + p.Name},
+		}
+	}
+}
+
+func (p ProbeSpec) attach(programs map[string]*ebpf.Program, b Backend) ([]link.Link, error) {
+	var links []link.Link
+	for _, a := range p.attachments(b) {
+		l, err := a.attach(programs)
+		if err != nil {
+			closeLinks(links)
+			return nil, fmt.Errorf("probe %s: %w", p.Name, err)
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
+
+// SetEnabledEvents attaches the probes of the given event types and detaches
+// all others. It can be called again when the rules are reloaded. Failing
+// optional probes are logged and recorded in Status; a failing required probe
+// is an error, and the probes attached by this call are detached again.
+func (r *LoaderResult) SetEnabledEvents(types []events.EventType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	want := make(map[events.EventType]bool, len(types))
+	for _, t := range types {
+		if !hasProbe(t) {
+			return fmt.Errorf("no probe for event type %s", t)
+		}
+		want[t] = true
+	}
+
+	failed := make(map[string]error)
+	var added []string
+	for _, p := range Probes {
+		if !want[p.Event] || r.attached[p.Name] != nil {
+			continue
+		}
+		links, err := p.attach(r.programs, r.backends[p.Name])
+		if err != nil {
+			if !p.Required {
+				log.Printf("Optional %v", err)
+				failed[p.Name] = err
+				continue
+			}
+			for _, name := range added {
+				closeLinks(r.attached[name])
+				delete(r.attached, name)
+			}
+			return err
+		}
+		r.attached[p.Name] = links
+		added = append(added, p.Name)
+		log.Printf("Probe %s attached (%s)", p.Name, r.backends[p.Name])
+	}
+
+	for _, p := range Probes {
+		links := r.attached[p.Name]
+		if links == nil || want[p.Event] {
+			continue
+		}
+		closeLinks(links)
+		delete(r.attached, p.Name)
+		log.Printf("Probe %s detached", p.Name)
+	}
+
+	r.enabled = want
+	r.failed = failed
+	return nil
+}
+
+// ActiveEvents returns the event types with at least one attached probe.
+func (r *LoaderResult) ActiveEvents() map[events.EventType]bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make(map[events.EventType]bool)
+	for _, p := range Probes {
+		if r.attached[p.Name] != nil {
+			res[p.Event] = true
+		}
+	}
+	return res
+}
+
+func (r *LoaderResult) Status() []ProbeStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make([]ProbeStatus, 0, len(Probes))
+	for _, p := range Probes {
+		res = append(res, ProbeStatus{
+			Name:     p.Name,
+			Event:    p.Event,
+			Required: p.Required,
+			Backend:  r.backends[p.Name],
+			Enabled:  r.enabled[p.Event],
+			Attached: r.attached[p.Name] != nil,
+			Err:      r.failed[p.Name],
+		})
+	}
+	return res
+}
+
+func (r *LoaderResult) detachAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, links := range r.attached {
+		closeLinks(links)
+		delete(r.attached, name)
+	}
+}
+
+// closeLinks detaches links in the reverse order of attaching them.
+func closeLinks(links []link.Link) {
+	for i := len(links) - 1; i >= 0; i-- {
+		links[i].Close()
+	}
+}
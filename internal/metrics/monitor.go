@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"log"
+	"net/http"
+)
+
+var Default = &Registry{}
+
+var (
+	EventsReceived = Default.NewCounterVec("monitor_events_received_total",
+		"Events read from the ring buffer, by event type.", "type")
+	ParseErrors = Default.NewCounterVec("monitor_parse_errors_total",
+		"Ring buffer records that could not be decoded, by event type.", "type")
+	RuleEvaluations = Default.NewCounterVec("monitor_rule_evaluations_total",
+		"Times a rule was evaluated against an event of a matching type.", "rule")
+	RuleMatches = Default.NewCounterVec("monitor_rule_matches_total",
+		"Times a rule matched an event.", "rule")
+	AlertsSuppressed = Default.NewCounterVec("monitor_alerts_suppressed_total",
+		"Alerts held back by a rule's suppression policy.", "rule")
+	AlertSinkFailures = Default.NewCounterVec("monitor_alert_sink_failures_total",
+		"Alerts that an output sink failed to deliver.", "sink")
+	RingBufferDrops = Default.NewCounterVec("monitor_ringbuf_drops_total",
+		"Events dropped in the kernel before reaching user space.", "type", "reason")
+	ExeInfoLookups = Default.NewCounterVec("monitor_exe_info_lookups_total",
+		"Executable metadata lookups, by result: cached, computed, error or queue_full.", "result")
+	AnalyzerLatency = Default.NewHistogramVec("monitor_analyzer_latency_seconds",
+		"Time spent by the analyzer processing one event.", DefaultLatencyBuckets, "type")
+)
+
+// Serve exposes the default registry on addr at /metrics in the background.
+func Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Default.Handler())
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Metrics server error: %v", err)
+		}
+	}()
+}
@@ -1,38 +1,86 @@
 package poller
 
 import (
-	"bytes"
-	"encoding/binary"
+	"diploma/internal/events"
+	"diploma/internal/metrics"
 	"errors"
 	"log"
-
-	"github.com/cilium/ebpf/ringbuf"
+	"time"
 )
 
-type EventParser interface {
-	Unmarshal(data []byte) error
+// Dispatcher reads an event source, usually the shared ring buffer, from a
+// single goroutine and routes every record to the handler registered for its
+// type, so events reach the analyzer in the order they were submitted on each
+// CPU.
+type Dispatcher struct {
+	handlers map[events.EventType]func(data []byte) error
+	// Recorder, if set before Start, receives every decoded event together
+	// with its raw record.
+	Recorder func(event events.EventGetter, raw []byte)
 }
 
-func Start[T any](rd *ringbuf.Reader, handler func(T)) {
-	go func() {
-		for {
-			record, err := rd.Read()
-			if err != nil {
-				if errors.Is(err, ringbuf.ErrClosed) {
-					return
-				}
-				log.Printf("Poller error reading ringbuf: %v", err)
-				continue
-			}
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		handlers: make(map[events.EventType]func(data []byte) error),
+	}
+}
 
-			var event T
+// Register routes records of eventType through decode to handler.
+func (d *Dispatcher) Register(eventType events.EventType, decode events.DecodeFunc, handler func(events.EventGetter)) {
+	d.handlers[eventType] = func(data []byte) error {
+		event, err := decode(data)
+		if err != nil {
+			return err
+		}
+		if d.Recorder != nil {
+			d.Recorder(event, data)
+		}
+
+		start := time.Now()
+		handler(event)
+		metrics.AnalyzerLatency.Observe(time.Since(start).Seconds(), eventType.String())
+		return nil
+	}
+}
 
-			if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
-				log.Printf("Poller parsing error: %v", err)
-				continue
+func (d *Dispatcher) Dispatch(data []byte) error {
+	hdr, err := events.ParseHeader(data)
+	if err != nil {
+		metrics.ParseErrors.Inc("unknown")
+		return err
+	}
+	metrics.EventsReceived.Inc(hdr.Type.String())
+
+	handle, ok := d.handlers[hdr.Type]
+	if !ok {
+		return nil
+	}
+	if err := handle(data[:hdr.Size]); err != nil {
+		metrics.ParseErrors.Inc(hdr.Type.String())
+		return err
+	}
+	return nil
+}
+
+// Run dispatches records from src until it is closed.
+func (d *Dispatcher) Run(src EventSource) {
+	for {
+		record, err := src.Read()
+		if err != nil {
+			if errors.Is(err, ErrClosed) {
+				return
 			}
+			log.Printf("Poller error reading events: %v", err)
+			continue
+		}
 
-			handler(event)
+		if err := d.Dispatch(record); err != nil {
+			log.Printf("Poller parsing error: %v", err)
 		}
-	}()
+	}
+}
+
+// Start runs the dispatcher on src in the background.
+func (d *Dispatcher) Start(src EventSource) {
+	go d.Run(src)
 }
@@ -0,0 +1,108 @@
+package poller
+
+import (
+	"diploma/internal/analyzer"
+	"diploma/internal/events"
+	"testing"
+)
+
+type alertSink struct {
+	alerts []analyzer.Alert
+}
+
+func (s *alertSink) Name() string {
+	return "test"
+}
+
+func (s *alertSink) Send(alert analyzer.Alert) error {
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+// record encodes an event the way the kernel lays it out in the ring buffer.
+func record(t *testing.T, event any) []byte {
+	t.Helper()
+	data, err := events.Encode(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestPipeline(t *testing.T) {
+	rules := []analyzer.Rule{
+		{
+			Name:       "shadow",
+			EventTypes: []string{"openat"},
+			Conditions: []analyzer.Condition{{Field: "fd.name", Operator: "=", Value: "/etc/shadow"}},
+		},
+		{
+			Name:       "k8s",
+			EventTypes: []string{"connect"},
+			Conditions: []analyzer.Condition{{Field: "fd.port", Operator: "=", Value: "6443"}},
+		},
+	}
+	engine := analyzer.New(analyzer.RulesConfig{Rules: rules})
+	engine.Offline = true
+	sink := &alertSink{}
+	engine.Sinks = []analyzer.Sink{sink}
+
+	d := NewDispatcher()
+	d.Register(events.EventOpenat, events.Decoder[events.OpenatEvent](), engine.Handle)
+	d.Register(events.EventConnect, events.Decoder[events.ConnectEvent](), engine.Handle)
+
+	var recorded []string
+	d.Recorder = func(event events.EventGetter, raw []byte) {
+		recorded = append(recorded, event.GetType())
+	}
+
+	common := events.CommonEvent{Pid: 42}
+	copy(common.Comm[:], "cat")
+
+	shadow := events.OpenatEvent{Common: common, Ret: 3, Filename: "shadow", Path: "/etc/shadow"}
+	shadow.Common.Header.Type = events.EventOpenat
+
+	passwd := shadow
+	passwd.Filename, passwd.Path = "/etc/passwd", "/etc/passwd"
+
+	conn := events.ConnectEvent{Common: common, Port: events.Ntohs(6443)}
+	conn.Common.Header.Type = events.EventConnect
+
+	src := NewChanSource(8)
+	src.Push(record(t, &shadow))
+	src.Push(record(t, &passwd))
+	src.Push(record(t, &conn))
+	// Truncated and unknown records are skipped.
+	src.Push(record(t, &shadow)[:100])
+	src.Push([]byte{1, 2, 3})
+	src.Close()
+
+	d.Run(src)
+
+	if len(sink.alerts) != 2 {
+		t.Fatalf("got %d alerts, want 2: %+v", len(sink.alerts), sink.alerts)
+	}
+	if sink.alerts[0].Rule != "shadow" || sink.alerts[1].Rule != "k8s" {
+		t.Errorf("alerts for %s, %s; want shadow, k8s", sink.alerts[0].Rule, sink.alerts[1].Rule)
+	}
+	if sink.alerts[0].ProcName != "cat" || sink.alerts[0].Pid != 42 {
+		t.Errorf("alert process %v(%v), want cat(42)", sink.alerts[0].ProcName, sink.alerts[0].Pid)
+	}
+	if len(recorded) != 3 {
+		t.Errorf("recorded %v, want 3 events", recorded)
+	}
+}
+
+func TestChanSourceClose(t *testing.T) {
+	src := NewChanSource(1)
+	src.Push([]byte{1})
+	src.Close()
+	src.Close()
+
+	if rec, err := src.Read(); err != nil || len(rec) != 1 {
+		t.Fatalf("Read = %v, %v; want queued record", rec, err)
+	}
+	if _, err := src.Read(); err != ErrClosed {
+		t.Fatalf("Read after drain: %v, want ErrClosed", err)
+	}
+}
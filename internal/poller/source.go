@@ -0,0 +1,74 @@
+package poller
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/cilium/ebpf/ringbuf"
+)
+
+// ErrClosed is returned by EventSource.Read once the source is closed.
+var ErrClosed = errors.New("event source closed")
+
+// EventSource yields raw records laid out like the C event structs, starting
+// with the event header.
+type EventSource interface {
+	// Read blocks until the next record is available. The record is only
+	// valid until the next call.
+	Read() ([]byte, error)
+	Close() error
+}
+
+// RingBufferSource reads records from a BPF ring buffer.
+type RingBufferSource struct {
+	rd     *ringbuf.Reader
+	record ringbuf.Record
+}
+
+func NewRingBufferSource(rd *ringbuf.Reader) *RingBufferSource {
+	return &RingBufferSource{rd: rd}
+}
+
+func (s *RingBufferSource) Read() ([]byte, error) {
+	if err := s.rd.ReadInto(&s.record); err != nil {
+		if errors.Is(err, ringbuf.ErrClosed) {
+			return nil, ErrClosed
+		}
+		return nil, err
+	}
+	return s.record.RawSample, nil
+}
+
+func (s *RingBufferSource) Close() error {
+	return s.rd.Close()
+}
+
+// ChanSource is an in-memory source fed with Push, e.g. by tests. Read
+// returns ErrClosed after Close once the pushed records are drained.
+type ChanSource struct {
+	ch   chan []byte
+	once sync.Once
+}
+
+func NewChanSource(buffer int) *ChanSource {
+	return &ChanSource{ch: make(chan []byte, buffer)}
+}
+
+// Push queues a record, blocking while the buffer is full. It must not be
+// called after Close.
+func (s *ChanSource) Push(record []byte) {
+	s.ch <- record
+}
+
+func (s *ChanSource) Read() ([]byte, error) {
+	record, ok := <-s.ch
+	if !ok {
+		return nil, ErrClosed
+	}
+	return record, nil
+}
+
+func (s *ChanSource) Close() error {
+	s.once.Do(func() { close(s.ch) })
+	return nil
+}
@@ -0,0 +1,127 @@
+package response
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type process struct {
+	pid  int
+	comm string
+}
+
+func kill(pid int) error {
+	return syscall.Kill(pid, syscall.SIGKILL)
+}
+
+func stop(pid int) error {
+	return syscall.Kill(pid, syscall.SIGSTOP)
+}
+
+// killTree stops the process and its descendants, so that none of them can
+// fork while the tree is walked, and then kills them all.
+func killTree(root int) error {
+	if err := stop(root); err != nil {
+		return err
+	}
+
+	tree := []int{root}
+	seen := map[int]bool{root: true}
+	// Children forked before the parent was stopped may fork in turn until
+	// they are stopped as well, so rescan until the tree stops growing.
+	for {
+		children, err := childrenMap()
+		if err != nil {
+			return err
+		}
+		grew := false
+		for i := 0; i < len(tree); i++ {
+			for _, child := range children[tree[i]] {
+				if !seen[child] {
+					seen[child] = true
+					tree = append(tree, child)
+					stop(child)
+					grew = true
+				}
+			}
+		}
+		if !grew {
+			break
+		}
+	}
+
+	var firstErr error
+	for _, pid := range tree {
+		if err := kill(pid); err != nil && err != syscall.ESRCH && firstErr == nil {
+			firstErr = fmt.Errorf("kill %d: %v", pid, err)
+		}
+	}
+	return firstErr
+}
+
+// childrenMap maps each pid to its children, read from /proc/<pid>/stat.
+func childrenMap() (map[int][]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[int][]int)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			continue
+		}
+		// comm may contain spaces and parentheses; fields resume after the last ')'.
+		stat := string(data)
+		fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+		if len(fields) < 2 {
+			continue
+		}
+		ppid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		res[ppid] = append(res[ppid], pid)
+	}
+	return res, nil
+}
+
+// cgroupDir returns the cgroup v2 directory of pid. The root cgroup is
+// refused, since freezing or isolating it would take down the whole host.
+func (r *Responder) cgroupDir(pid int) (string, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		rel, ok := strings.CutPrefix(scanner.Text(), "0::")
+		if !ok {
+			continue
+		}
+		if rel == "/" {
+			return "", fmt.Errorf("process is in the root cgroup")
+		}
+		return filepath.Join(r.cfg.CgroupRoot, rel), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no cgroup v2 membership for pid %d", pid)
+}
+
+func freeze(dir string) error {
+	return os.WriteFile(filepath.Join(dir, "cgroup.freeze"), []byte("1"), 0)
+}
@@ -0,0 +1,224 @@
+// Package response runs the actions attached to rules, such as killing or
+// freezing the offending process, and records each of them in an audit log.
+package response
+
+import (
+	"diploma/internal/analyzer"
+	"diploma/internal/config"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	ActionKill         = "kill"
+	ActionKillTree     = "kill_tree"
+	ActionStop         = "stop"
+	ActionFreezeCgroup = "freeze_cgroup"
+	ActionDropNetwork  = "drop_network"
+)
+
+var knownActions = map[string]bool{
+	ActionKill:         true,
+	ActionKillTree:     true,
+	ActionStop:         true,
+	ActionFreezeCgroup: true,
+	ActionDropNetwork:  true,
+}
+
+// Isolator cuts a cgroup off the network; implemented by the loader.
+type Isolator interface {
+	IsolateCgroup(path string) error
+}
+
+// AuditRecord is one line of the audit log.
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Rule   string    `json:"rule"`
+	Action string    `json:"action"`
+	Pid    int       `json:"pid"`
+	Comm   string    `json:"comm,omitempty"`
+	Cgroup string    `json:"cgroup,omitempty"`
+	// Result is done, failed, rate_limited or refused.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Responder is an alert sink executing the alert's actions.
+type Responder struct {
+	cfg      config.ResponseConfig
+	isolator Isolator
+	self     int
+
+	mu      sync.Mutex
+	audit   *os.File
+	windows map[string]*window // by rule name
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+func New(cfg config.ResponseConfig, isolator Isolator) (*Responder, error) {
+	f, err := os.OpenFile(cfg.AuditLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &Responder{
+		cfg:      cfg,
+		isolator: isolator,
+		self:     os.Getpid(),
+		audit:    f,
+		windows:  make(map[string]*window),
+	}, nil
+}
+
+func (r *Responder) Close() error {
+	return r.audit.Close()
+}
+
+// CheckRules returns an error naming the first unknown action.
+func CheckRules(rules []analyzer.Rule) error {
+	for _, rule := range rules {
+		for _, a := range rule.Actions {
+			if !knownActions[a] {
+				return fmt.Errorf("rule %q: unknown action %q", rule.Name, a)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Responder) Name() string {
+	return "response"
+}
+
+func (r *Responder) Send(alert analyzer.Alert) error {
+	if len(alert.Actions) == 0 || alert.Event == nil {
+		return nil
+	}
+
+	pid, _ := alert.Event.GetField("proc.pid")
+	comm, _ := alert.Event.GetField("proc.name")
+	target := process{pid: toInt(pid), comm: fmt.Sprint(comm)}
+
+	var failed []string
+	for _, action := range alert.Actions {
+		rec := AuditRecord{
+			Time:   time.Now(),
+			Rule:   alert.Rule,
+			Action: action,
+			Pid:    target.pid,
+			Comm:   target.comm,
+		}
+
+		switch {
+		case !knownActions[action]:
+			rec.Result, rec.Error = "refused", "unknown action"
+		case r.protected(target.pid):
+			rec.Result, rec.Error = "refused", "protected process"
+		case !r.allow(alert.Rule):
+			rec.Result = "rate_limited"
+		default:
+			cgroup, err := r.run(action, target)
+			rec.Cgroup = cgroup
+			rec.Result = "done"
+			if err != nil {
+				rec.Result, rec.Error = "failed", err.Error()
+				failed = append(failed, action)
+			}
+		}
+		r.record(rec)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("actions %v failed for pid %d", failed, target.pid)
+	}
+	return nil
+}
+
+func (r *Responder) run(action string, target process) (cgroup string, err error) {
+	switch action {
+	case ActionKill:
+		return "", kill(target.pid)
+	case ActionKillTree:
+		return "", killTree(target.pid)
+	case ActionStop:
+		return "", stop(target.pid)
+	case ActionFreezeCgroup:
+		cgroup, err = r.targetCgroup(target.pid)
+		if err != nil {
+			return "", err
+		}
+		return cgroup, freeze(cgroup)
+	case ActionDropNetwork:
+		cgroup, err = r.targetCgroup(target.pid)
+		if err != nil {
+			return "", err
+		}
+		return cgroup, r.isolator.IsolateCgroup(cgroup)
+	}
+	return "", fmt.Errorf("unknown action %q", action)
+}
+
+// targetCgroup returns the cgroup of pid unless the monitor runs in it too.
+func (r *Responder) targetCgroup(pid int) (string, error) {
+	dir, err := r.cgroupDir(pid)
+	if err != nil {
+		return "", err
+	}
+	if own, err := r.cgroupDir(r.self); err == nil && own == dir {
+		return "", fmt.Errorf("cgroup %s contains the monitor", dir)
+	}
+	return dir, nil
+}
+
+// protected refuses init, kthreadd and the monitor itself.
+func (r *Responder) protected(pid int) bool {
+	return pid <= 2 || pid == r.self
+}
+
+// allow counts an action against the rule's fixed window.
+func (r *Responder) allow(rule string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	w := r.windows[rule]
+	if w == nil || now.Sub(w.start) >= r.cfg.Window {
+		w = &window{start: now}
+		r.windows[rule] = w
+	}
+	if w.count >= r.cfg.MaxActions {
+		return false
+	}
+	w.count++
+	return true
+}
+
+func (r *Responder) record(rec AuditRecord) {
+	log.Printf("[RESPONSE] %s: %s pid=%d (%s) -> %s %s", rec.Rule, rec.Action, rec.Pid, rec.Comm, rec.Result, rec.Error)
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("Audit record error: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.audit.Write(append(data, '\n')); err != nil {
+		log.Printf("Audit log write error: %v", err)
+	}
+}
+
+func toInt(v interface{}) int {
+	if n, ok := v.(int); ok {
+		return n
+	}
+	return 0
+}
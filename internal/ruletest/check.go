@@ -0,0 +1,35 @@
+package ruletest
+
+import (
+	"diploma/internal/analyzer"
+	"diploma/internal/config"
+	"testing"
+)
+
+// Check loads the rules and fixtures files and fails t for every case that
+// does not pass, so that a package shipping rules can test them with one
+// call. Rules without fixtures are logged.
+func Check(t testing.TB, rulesPath, fixturesPath string) {
+	t.Helper()
+
+	rulesCfg, err := config.LoadRules(rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := analyzer.CheckRules(rulesCfg.Rules); err != nil {
+		t.Fatal(err)
+	}
+	suite, err := Load(fixturesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, res := range Run(rulesCfg.Rules, suite) {
+		if !res.Passed() {
+			t.Error(res)
+		}
+	}
+	for _, name := range Untested(rulesCfg.Rules, suite) {
+		t.Logf("rule %q has no fixtures", name)
+	}
+}
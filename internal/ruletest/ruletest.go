@@ -0,0 +1,126 @@
+// Package ruletest checks rules against fixture events built with the real
+// event types, so rule edits can be validated without root or BPF.
+package ruletest
+
+import (
+	"diploma/internal/analyzer"
+	"diploma/internal/events"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Case is one fixture event and whether the rule is expected to match it.
+type Case struct {
+	Rule string `yaml:"rule"`
+	// Name optionally describes the case in reports.
+	Name string `yaml:"name"`
+	// Step selects the step of a sequence rule, counting from 0.
+	Step int `yaml:"step"`
+	// EventType defaults to the first event type of the rule or step.
+	EventType string            `yaml:"event_type"`
+	Fields    map[string]string `yaml:"fields"`
+	Match     bool              `yaml:"match"`
+}
+
+type Suite struct {
+	Tests []Case `yaml:"tests"`
+}
+
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse yaml: %w", err)
+	}
+	return &suite, nil
+}
+
+type Result struct {
+	Case    Case
+	Matched bool
+	// Err is set when the case could not be evaluated.
+	Err error
+}
+
+func (r Result) Passed() bool {
+	return r.Err == nil && r.Matched == r.Case.Match
+}
+
+func (r Result) String() string {
+	name := r.Case.Rule
+	if r.Case.Name != "" {
+		name += ": " + r.Case.Name
+	}
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("%s: %v", name, r.Err)
+	case r.Passed():
+		return name
+	case r.Case.Match:
+		return fmt.Sprintf("%s: expected match, got none (fields %v)", name, r.Case.Fields)
+	default:
+		return fmt.Sprintf("%s: unexpected match (fields %v)", name, r.Case.Fields)
+	}
+}
+
+// Run evaluates each case with Rule.CheckEvent of the rule of the same name.
+func Run(rules []analyzer.Rule, suite *Suite) []Result {
+	byName := make(map[string]*analyzer.Rule, len(rules))
+	for i := range rules {
+		byName[rules[i].Name] = &rules[i]
+	}
+
+	res := make([]Result, len(suite.Tests))
+	for i, tc := range suite.Tests {
+		res[i] = Result{Case: tc}
+		rule, ok := byName[tc.Rule]
+		if !ok {
+			res[i].Err = fmt.Errorf("no such rule")
+			continue
+		}
+		res[i].Matched, res[i].Err = runCase(rule, tc)
+	}
+	return res
+}
+
+func runCase(rule *analyzer.Rule, tc Case) (bool, error) {
+	parts := rule.EventRules()
+	if tc.Step < 0 || tc.Step >= len(parts) {
+		return false, fmt.Errorf("rule has no step %d", tc.Step)
+	}
+	part := parts[tc.Step]
+
+	eventType := tc.EventType
+	if eventType == "" {
+		if len(part.EventTypes) == 0 {
+			return false, fmt.Errorf("rule has no event types, set event_type")
+		}
+		eventType = part.EventTypes[0]
+	}
+
+	evt, err := events.FromFields(eventType, tc.Fields)
+	if err != nil {
+		return false, err
+	}
+	return part.CheckEvent(evt), nil
+}
+
+// Untested returns the names of rules without any case.
+func Untested(rules []analyzer.Rule, suite *Suite) []string {
+	tested := make(map[string]bool)
+	for _, tc := range suite.Tests {
+		tested[tc.Rule] = true
+	}
+	var res []string
+	for _, rule := range rules {
+		if !tested[rule.Name] {
+			res = append(res, rule.Name)
+		}
+	}
+	return res
+}
@@ -0,0 +1,47 @@
+package ruletest
+
+import (
+	"diploma/internal/analyzer"
+	"strings"
+	"testing"
+)
+
+func TestShippedRules(t *testing.T) {
+	Check(t, "../../configs/security_rules.yaml", "../../configs/rule_tests.yaml")
+}
+
+func TestRunReportsFailures(t *testing.T) {
+	rules := []analyzer.Rule{{
+		Name:       "shadow",
+		EventTypes: []string{"openat"},
+		Conditions: []analyzer.Condition{{Field: "fd.name", Operator: "=", Value: "/etc/shadow"}},
+	}}
+	suite := &Suite{Tests: []Case{
+		{Rule: "shadow", Fields: map[string]string{"fd.name": "/etc/shadow"}, Match: true},
+		{Rule: "shadow", Fields: map[string]string{"fd.name": "/etc/passwd"}, Match: true},
+		{Rule: "missing", Match: true},
+		{Rule: "shadow", Fields: map[string]string{"fd.nmae": "/etc/shadow"}, Match: true},
+		{Rule: "shadow", Step: 1, Match: true},
+	}}
+
+	want := []string{"", "expected match", "no such rule", "unknown field", "no step 1"}
+	for i, res := range Run(rules, suite) {
+		if want[i] == "" {
+			if !res.Passed() {
+				t.Errorf("case %d: %s", i, res)
+			}
+			continue
+		}
+		if res.Passed() || !strings.Contains(res.String(), want[i]) {
+			t.Errorf("case %d: got %q, want failure mentioning %q", i, res, want[i])
+		}
+	}
+}
+
+func TestUntested(t *testing.T) {
+	rules := []analyzer.Rule{{Name: "a"}, {Name: "b"}}
+	got := Untested(rules, &Suite{Tests: []Case{{Rule: "a"}}})
+	if len(got) != 1 || got[0] != "b" {
+		t.Fatalf("Untested = %v, want [b]", got)
+	}
+}